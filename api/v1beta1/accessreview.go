@@ -0,0 +1,132 @@
+/*
+Copyright 2020 Vladislav Poberezhny.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+// VerbUse is the RBAC verb on a binding which permits using it if the binding requires it
+const VerbUse = "use"
+
+// accessReviewTTL is the time the result of a subject access review is reused for
+const accessReviewTTL = 10 * time.Second
+
+// accessReviewResult is the cached result of a subject access review
+type accessReviewResult struct {
+	allowed    bool
+	expiryTime time.Time
+}
+
+// Results of recent subject access reviews by user and binding
+var (
+	accessReviews    = make(map[string]accessReviewResult)
+	accessReviewsMux sync.Mutex
+)
+
+// accessReviewKey identifies a subject access review of a binding by every attribute of the user it is made for
+func accessReviewKey(bindingName string, requester *authenticationv1.UserInfo) (string, error) {
+
+	groups := append([]string{}, requester.Groups...)
+	sort.Strings(groups)
+
+	// Maps are encoded with sorted keys
+	key, err := json.Marshal(struct {
+		Binding  string                                 `json:"binding"`
+		Username string                                 `json:"username"`
+		UID      string                                 `json:"uid"`
+		Groups   []string                               `json:"groups"`
+		Extra    map[string]authenticationv1.ExtraValue `json:"extra"`
+	}{bindingName, requester.Username, requester.UID, groups, requester.Extra})
+	if err != nil {
+		return "", err
+	}
+
+	return string(key), nil
+}
+
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+// canUseBinding checks whether the user holds the use verb on the binding, which is never the case for an unknown user
+func canUseBinding(bindingName string, requester *authenticationv1.UserInfo) (bool, error) {
+
+	if requester == nil {
+		return false, nil
+	}
+
+	// Reuse recent results of the same user, whose extra attributes such as token scopes may change the result as well
+	key, err := accessReviewKey(bindingName, requester)
+	if err != nil {
+		return false, errors.New("an error occurred while reviewing access to binding " + bindingName + ": " + err.Error())
+	}
+
+	accessReviewsMux.Lock()
+	result, ok := accessReviews[key]
+	accessReviewsMux.Unlock()
+	if ok && time.Now().Before(result.expiryTime) {
+		return result.allowed, nil
+	}
+
+	extra := make(map[string]authorizationv1.ExtraValue, len(requester.Extra))
+	for extraKey, extraValue := range requester.Extra {
+		extra[extraKey] = authorizationv1.ExtraValue(extraValue)
+	}
+
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   requester.Username,
+			Groups: requester.Groups,
+			UID:    requester.UID,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Group:    GroupVersion.Group,
+				Version:  GroupVersion.Version,
+				Resource: "managedresourcebindings",
+				Name:     bindingName,
+				Verb:     VerbUse,
+			},
+		},
+	}
+	if err := getClient().Create(context.Background(), review); err != nil {
+		return false, errors.New("an error occurred while reviewing access to binding " + bindingName + ": " + err.Error())
+	}
+
+	// Store the result and evict expired results
+	accessReviewsMux.Lock()
+	defer accessReviewsMux.Unlock()
+
+	now := time.Now()
+	for cachedKey, cachedResult := range accessReviews {
+		if now.After(cachedResult.expiryTime) {
+			delete(accessReviews, cachedKey)
+		}
+	}
+	accessReviews[key] = accessReviewResult{
+		allowed:    review.Status.Allowed,
+		expiryTime: now.Add(accessReviewTTL),
+	}
+
+	return review.Status.Allowed, nil
+}
@@ -0,0 +1,31 @@
+package v1beta1
+
+import (
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+func TestAccessReviewKey(t *testing.T) {
+
+	requester := &authenticationv1.UserInfo{Username: "jane", UID: "1234", Groups: []string{"b", "a"}}
+	key, err := accessReviewKey("binding", requester)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Group order does not matter, while every other attribute does
+	same := &authenticationv1.UserInfo{Username: "jane", UID: "1234", Groups: []string{"a", "b"}}
+	if sameKey, _ := accessReviewKey("binding", same); sameKey != key {
+		t.Errorf("expected reordered groups to share the key, got %s and %s", key, sameKey)
+	}
+
+	for name, other := range map[string]*authenticationv1.UserInfo{
+		"uid":   {Username: "jane", UID: "5678", Groups: []string{"a", "b"}},
+		"extra": {Username: "jane", UID: "1234", Groups: []string{"a", "b"}, Extra: map[string]authenticationv1.ExtraValue{"scopes": {"user:info"}}},
+	} {
+		if otherKey, _ := accessReviewKey("binding", other); otherKey == key {
+			t.Errorf("expected a different %s to change the key %s", name, key)
+		}
+	}
+}
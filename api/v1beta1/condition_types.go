@@ -0,0 +1,165 @@
+/*
+Copyright 2020 Vladislav Poberezhny.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"unicode/utf8"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Condition types reported by managed resources
+const (
+	ConditionReady      = "Ready"
+	ConditionSynced     = "Synced"
+	ConditionAuthorized = "Authorized"
+	ConditionDeleting   = "Deleting"
+)
+
+// Condition reasons reported by managed resources
+const (
+	ReasonReconciled       = "Reconciled"
+	ReasonApplied          = "Applied"
+	ReasonWriteOnce        = "WriteOnce"
+	ReasonPermitted        = "Permitted"
+	ReasonSourceError      = "SourceError"
+	ReasonApplyFailed      = "ApplyFailed"
+	ReasonFieldConflict    = "FieldConflict"
+	ReasonPruneFailed      = "PruneFailed"
+	ReasonPermissionDenied = "PermissionDenied"
+	ReasonInvalidOverlay   = "InvalidOverlay"
+	ReasonFinalizing       = "Finalizing"
+	ReasonDeleteFailed     = "DeleteFailed"
+)
+
+// Condition reasons reported by operator configs
+const (
+	ReasonConfigApplied = "Applied"
+	ReasonInvalidConfig = "InvalidConfig"
+	ReasonIgnored       = "Ignored"
+)
+
+// ConditionStatus is the status of a condition
+// +kubebuilder:validation:Enum=True;False;Unknown
+type ConditionStatus string
+
+// Valid condition statuses
+const (
+	ConditionTrue    ConditionStatus = "True"
+	ConditionFalse   ConditionStatus = "False"
+	ConditionUnknown ConditionStatus = "Unknown"
+)
+
+// Condition is a single observation of a managed resource state.
+// It mirrors the metav1.Condition type which is not available in the apimachinery version in use.
+type Condition struct {
+
+	// +kubebuilder:validation:MaxLength=316
+	// +kubebuilder:validation:Pattern=`^([a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*/)?(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])$`
+	Type string `json:"type"`
+
+	Status ConditionStatus `json:"status"`
+
+	// +kubebuilder:validation:Minimum=0
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+
+	// +kubebuilder:validation:MaxLength=1024
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:Pattern=`^[A-Za-z]([A-Za-z0-9_,:]*[A-Za-z0-9_])?$`
+	Reason string `json:"reason"`
+
+	// +kubebuilder:validation:MaxLength=32768
+	Message string `json:"message"`
+}
+
+// MaxMessageLength is the maximum length of a condition message, longer error messages are truncated before they are written to the status
+const MaxMessageLength = 32768
+
+// truncatedSuffix marks a message which was truncated
+const truncatedSuffix = "... (truncated)"
+
+// TruncateMessage shortens a message to MaxMessageLength bytes, cutting it at a character boundary
+func TruncateMessage(message string) string {
+
+	if len(message) <= MaxMessageLength {
+		return message
+	}
+
+	cut := MaxMessageLength - len(truncatedSuffix)
+	for cut > 0 && !utf8.RuneStart(message[cut]) {
+		cut--
+	}
+
+	return message[:cut] + truncatedSuffix
+}
+
+// FindCondition returns the condition of the given type or nil if it is not present
+func FindCondition(conditions []Condition, conditionType string) *Condition {
+
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+
+	return nil
+}
+
+// SetCondition adds or updates a condition, only moving its transition time if the status changed
+func SetCondition(conditions *[]Condition, newCondition Condition) {
+
+	newCondition.Message = TruncateMessage(newCondition.Message)
+
+	// Add condition if not present
+	existingCondition := FindCondition(*conditions, newCondition.Type)
+	if existingCondition == nil {
+		if newCondition.LastTransitionTime.IsZero() {
+			newCondition.LastTransitionTime = metav1.Now()
+		}
+		*conditions = append(*conditions, newCondition)
+		return
+	}
+
+	// Move transition time only if status changed
+	if existingCondition.Status != newCondition.Status {
+		existingCondition.Status = newCondition.Status
+		if newCondition.LastTransitionTime.IsZero() {
+			existingCondition.LastTransitionTime = metav1.Now()
+		} else {
+			existingCondition.LastTransitionTime = newCondition.LastTransitionTime
+		}
+	}
+
+	existingCondition.Reason = newCondition.Reason
+	existingCondition.Message = newCondition.Message
+	existingCondition.ObservedGeneration = newCondition.ObservedGeneration
+}
+
+// RemoveCondition removes the condition of the given type if it is present
+func RemoveCondition(conditions *[]Condition, conditionType string) {
+
+	newConditions := make([]Condition, 0, len(*conditions))
+	for _, condition := range *conditions {
+		if condition.Type != conditionType {
+			newConditions = append(newConditions, condition)
+		}
+	}
+
+	*conditions = newConditions
+}
@@ -0,0 +1,37 @@
+package v1beta1
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateMessage(t *testing.T) {
+
+	short := "an error occurred"
+	if truncated := TruncateMessage(short); truncated != short {
+		t.Errorf("expected a short message to be kept, got %q", truncated)
+	}
+
+	// Multi-byte characters which straddle the limit are not split
+	for name, message := range map[string]string{
+		"ascii":      strings.Repeat("a", MaxMessageLength+1),
+		"multi-byte": strings.Repeat("ä", MaxMessageLength),
+	} {
+		t.Run(name, func(t *testing.T) {
+			truncated := TruncateMessage(message)
+			if len(truncated) > MaxMessageLength {
+				t.Errorf("expected at most %d bytes, got %d", MaxMessageLength, len(truncated))
+			}
+			if !utf8.ValidString(truncated) || !strings.HasSuffix(truncated, truncatedSuffix) {
+				t.Errorf("expected a valid truncated message, got %q", truncated[len(truncated)-32:])
+			}
+		})
+	}
+
+	var conditions []Condition
+	SetCondition(&conditions, Condition{Type: ConditionReady, Status: ConditionFalse, Reason: ReasonApplyFailed, Message: strings.Repeat("a", 2*MaxMessageLength)})
+	if len(conditions[0].Message) > MaxMessageLength {
+		t.Errorf("expected the condition message to be truncated, got %d bytes", len(conditions[0].Message))
+	}
+}
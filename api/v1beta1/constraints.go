@@ -0,0 +1,157 @@
+/*
+Copyright 2020 Vladislav Poberezhny.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/go-openapi/validate"
+	"github.com/jeremywohl/flatten"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// identityFields are the fields every object may set, since they are matched by the binding item itself
+var identityFields = []string{"apiVersion", "kind", "metadata.name", "metadata.namespace"}
+
+// ConstraintViolation describes a field of an object which violates the constraints of a binding item
+type ConstraintViolation struct {
+
+	// Path is the path of the violating field
+	Path string
+
+	// Message describes the violation
+	Message string
+}
+
+// Error implements error
+func (v *ConstraintViolation) Error() string {
+	return v.Path + ": " + v.Message
+}
+
+// Check ensures the object satisfies the constraints, returning a constraint violation for the first violating field
+func (c *ObjectConstraints) Check(object runtime.Object) error {
+
+	objectMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+	if err != nil {
+		return errors.New("an error occurred while reading object: " + err.Error())
+	}
+
+	// Ensure required labels are present with their required value, if any
+	labels, _, err := unstructured.NestedStringMap(objectMap, "metadata", "labels")
+	if err != nil {
+		return errors.New("an error occurred while reading object labels: " + err.Error())
+	}
+	labelKeys := make([]string, 0, len(c.RequiredLabels))
+	for key := range c.RequiredLabels {
+		labelKeys = append(labelKeys, key)
+	}
+	sort.Strings(labelKeys)
+	for _, key := range labelKeys {
+		value, ok := labels[key]
+		if !ok {
+			return &ConstraintViolation{Path: "metadata.labels." + key, Message: "required label is missing"}
+		} else if requiredValue := c.RequiredLabels[key]; requiredValue != "" && value != requiredValue {
+			return &ConstraintViolation{Path: "metadata.labels." + key, Message: "label must be " + requiredValue}
+		}
+	}
+
+	// Ensure only allowed fields and no forbidden fields are set
+	flatObject, err := flatten.Flatten(objectMap, "", flatten.DotStyle)
+	if err != nil {
+		return errors.New("an error occurred while reading object fields: " + err.Error())
+	}
+	paths := make([]string, 0, len(flatObject))
+	for path := range flatObject {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if matchesAnyFieldPath(path, c.ForbiddenFields) {
+			return &ConstraintViolation{Path: path, Message: "field is forbidden"}
+		}
+		if len(c.AllowedFields) != 0 && !matchesAnyFieldPath(path, identityFields) && !matchesAnyFieldPath(path, c.AllowedFields) {
+			return &ConstraintViolation{Path: path, Message: "field is not allowed"}
+		}
+	}
+
+	// Validate the object against the schema
+	if c.Schema != nil && c.Schema.Raw != nil {
+		schemaValidator, err := newSchemaValidator(c.Schema.Raw)
+		if err != nil {
+			return err
+		}
+		if errs := validation.ValidateCustomResource(nil, objectMap, schemaValidator); len(errs) != 0 {
+			return &ConstraintViolation{Path: errs[0].Field, Message: errs[0].ErrorBody()}
+		}
+	}
+
+	return nil
+}
+
+// newSchemaValidator parses an OpenAPI v3 schema as used by custom resource definitions
+func newSchemaValidator(schemaBytes []byte) (*validate.SchemaValidator, error) {
+
+	schema := &apiextensionsv1.JSONSchemaProps{}
+	if err := json.Unmarshal(schemaBytes, schema); err != nil {
+		return nil, errors.New("an error occurred while reading constraint schema: " + err.Error())
+	}
+
+	internalSchema := &apiextensions.JSONSchemaProps{}
+	if err := apiextensionsv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(schema, internalSchema, nil); err != nil {
+		return nil, errors.New("an error occurred while reading constraint schema: " + err.Error())
+	}
+
+	schemaValidator, _, err := validation.NewSchemaValidator(&apiextensions.CustomResourceValidation{OpenAPIV3Schema: internalSchema})
+	if err != nil {
+		return nil, errors.New("an error occurred while reading constraint schema: " + err.Error())
+	}
+
+	return schemaValidator, nil
+}
+
+// matchesAnyFieldPath checks whether the field path is within any of the field paths, where * matches any single segment
+func matchesAnyFieldPath(path string, fieldPaths []string) bool {
+
+	segments := strings.Split(path, ".")
+	for _, fieldPath := range fieldPaths {
+
+		fieldSegments := strings.Split(fieldPath, ".")
+		if len(fieldSegments) > len(segments) {
+			continue
+		}
+
+		match := true
+		for i, fieldSegment := range fieldSegments {
+			if fieldSegment != "*" && fieldSegment != segments[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,129 @@
+package v1beta1
+
+import (
+	"errors"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+func TestObjectConstraintsCheck(t *testing.T) {
+
+	deployment := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: team-a
+  labels:
+    team: payments
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: app
+        image: registry.example.com/app:1.2.3
+        securityContext:
+          privileged: true
+`
+
+	replicasSchema := &runtime.RawExtension{Raw: []byte(`{"type":"object","properties":{"spec":{"type":"object","properties":{"replicas":{"type":"integer","maximum":3}}}}}`)}
+	strictSchema := &runtime.RawExtension{Raw: []byte(`{"type":"object","properties":{"spec":{"type":"object","properties":{"replicas":{"type":"integer","maximum":1}}}}}`)}
+
+	tests := []struct {
+		name        string
+		constraints ObjectConstraints
+		path        string
+		wantErr     bool
+	}{
+		{name: "no constraints"},
+		{name: "required label", constraints: ObjectConstraints{RequiredLabels: map[string]string{"team": ""}}},
+		{name: "required label value", constraints: ObjectConstraints{RequiredLabels: map[string]string{"team": "payments"}}},
+		{name: "missing label", constraints: ObjectConstraints{RequiredLabels: map[string]string{"owner": ""}}, path: "metadata.labels.owner", wantErr: true},
+		{name: "wrong label value", constraints: ObjectConstraints{RequiredLabels: map[string]string{"team": "billing"}}, path: "metadata.labels.team", wantErr: true},
+		{name: "forbidden field", constraints: ObjectConstraints{ForbiddenFields: []string{"spec.template.spec.containers.*.securityContext.privileged"}}, path: "spec.template.spec.containers.0.securityContext.privileged", wantErr: true},
+		{name: "forbidden parent field", constraints: ObjectConstraints{ForbiddenFields: []string{"spec.template.spec.containers.*.securityContext"}}, path: "spec.template.spec.containers.0.securityContext.privileged", wantErr: true},
+		{name: "forbidden field not set", constraints: ObjectConstraints{ForbiddenFields: []string{"spec.template.spec.hostNetwork"}}},
+		{name: "forbidden field is not a prefix match", constraints: ObjectConstraints{ForbiddenFields: []string{"spec.replica"}}},
+		{name: "allowed fields", constraints: ObjectConstraints{AllowedFields: []string{"metadata.labels", "spec"}}},
+		{name: "field not allowed", constraints: ObjectConstraints{AllowedFields: []string{"metadata.labels", "spec.replicas"}}, path: "spec.template.spec.containers.0.image", wantErr: true},
+		{name: "forbidden within allowed", constraints: ObjectConstraints{AllowedFields: []string{"metadata.labels", "spec"}, ForbiddenFields: []string{"spec.*.spec.containers.*.securityContext"}}, path: "spec.template.spec.containers.0.securityContext.privileged", wantErr: true},
+		{name: "valid against schema", constraints: ObjectConstraints{Schema: replicasSchema}},
+		{name: "invalid against schema", constraints: ObjectConstraints{Schema: strictSchema}, path: "spec.replicas", wantErr: true},
+	}
+
+	object := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal([]byte(deployment), &object.Object); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			err := test.constraints.Check(object)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if err == nil {
+				return
+			}
+
+			violation := &ConstraintViolation{}
+			if !errors.As(err, &violation) {
+				t.Fatalf("Check() error = %v, want a constraint violation", err)
+			}
+			if violation.Path != test.path {
+				t.Errorf("violation path = %s, want %s", violation.Path, test.path)
+			}
+		})
+	}
+}
+
+func TestNewSchemaValidator(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		schema  string
+		wantErr bool
+	}{
+		{name: "object", schema: `{"type":"object","properties":{"spec":{"type":"object"}}}`},
+		{name: "not JSON", schema: `{`, wantErr: true},
+		{name: "wrong field type", schema: `{"type":"object","properties":"spec"}`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := newSchemaValidator([]byte(test.schema)); (err != nil) != test.wantErr {
+				t.Errorf("newSchemaValidator() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestMatchesAnyFieldPath(t *testing.T) {
+
+	tests := []struct {
+		path       string
+		fieldPaths []string
+		matches    bool
+	}{
+		{path: "spec.replicas", fieldPaths: []string{"spec.replicas"}, matches: true},
+		{path: "spec.template.spec", fieldPaths: []string{"spec"}, matches: true},
+		{path: "spec.containers.0.image", fieldPaths: []string{"spec.containers.*.image"}, matches: true},
+		{path: "spec.containers.0.name", fieldPaths: []string{"metadata", "spec.containers.*.image"}, matches: false},
+		{path: "spec", fieldPaths: []string{"spec.replicas"}, matches: false},
+		{path: "spec.replicasCount", fieldPaths: []string{"spec.replicas"}, matches: false},
+		{path: "spec.replicas", fieldPaths: nil, matches: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			if matches := matchesAnyFieldPath(test.path, test.fieldPaths); matches != test.matches {
+				t.Errorf("matchesAnyFieldPath(%s, %v) = %v, want %v", test.path, test.fieldPaths, matches, test.matches)
+			}
+		})
+	}
+}
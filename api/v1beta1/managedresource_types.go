@@ -17,8 +17,16 @@ limitations under the License.
 package v1beta1
 
 import (
+	"encoding/json"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"operator/pkg/utils"
 )
@@ -30,18 +38,296 @@ type ManagedResourceSpec struct {
 	// +kubebuilder:validation:XPreserveUnknownFields
 	// +nullable
 	Overwrite runtime.RawExtension `json:"overwrite,omitempty"`
+
+	// OverwriteStrategy is either Merge, which merges maps and replaces lists, JSONMerge, which applies the overwrite as an RFC 7386 JSON merge patch,
+	// or StrategicMerge, which applies it as a strategic merge patch using the patch merge keys of the object kind, defaults to Merge
+	// +optional
+	OverwriteStrategy utils.OverwriteStrategy `json:"overwriteStrategy,omitempty"`
+
+	// OverwriteMode is either Once, which embeds the overwritten objects and removes the overwrite, or Persistent,
+	// which keeps the overwrite and applies it again on every update and reconciliation, defaults to Once
+	// +optional
+	OverwriteMode OverwriteMode `json:"overwriteMode,omitempty"`
+
+	// Patches are RFC 6902 JSON patch operations applied in order to each object they target after the overwrite
+	// +optional
+	Patches []utils.PatchOperation `json:"patches,omitempty"`
+
+	// +optional
+	Apply ApplyStruct `json:"apply,omitempty"`
+
+	// Parameters are the values a templated source is rendered with as .Parameters
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
+
+	// ParametersConfigMap is the name of a ConfigMap within the managed resource namespace whose data is added to the parameters, which take precedence
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern="^[a-z0-9]([-a-z0-9]*[a-z0-9])?([.][a-z0-9]([-a-z0-9]*[a-z0-9])?)*$"
+	// +optional
+	ParametersConfigMap string `json:"parametersConfigMap,omitempty"`
+}
+
+// OverwriteMode is whether the overwrite is applied once or on every update
+// +kubebuilder:validation:Enum=Once;Persistent
+type OverwriteMode string
+
+// Valid overwrite modes
+const (
+	OverwriteModeOnce       OverwriteMode = "Once"
+	OverwriteModePersistent OverwriteMode = "Persistent"
+)
+
+// ApplyMode is the way the managed object is written to the cluster
+// +kubebuilder:validation:Enum=Update;ServerSide
+type ApplyMode string
+
+// Valid apply modes
+const (
+	ApplyModeUpdate     ApplyMode = "Update"
+	ApplyModeServerSide ApplyMode = "ServerSide"
+)
+
+// ApplyStruct defines how the managed object is written to the cluster
+type ApplyStruct struct {
+
+	// Mode is either Update, which replaces the whole object, or ServerSide, which only sets the fields defined by the source
+	// +optional
+	Mode ApplyMode `json:"mode,omitempty"`
+
+	// ForceConflicts takes ownership of fields which are owned by other field managers when using server-side apply
+	// +optional
+	ForceConflicts bool `json:"forceConflicts,omitempty"`
+}
+
+// PatchOptions returns the server-side apply options for the managed object
+func (a ApplyStruct) PatchOptions() []client.PatchOption {
+
+	patchOptions := []client.PatchOption{client.FieldOwner(utils.FieldManager)}
+	if a.ForceConflicts {
+		patchOptions = append(patchOptions, client.ForceOwnership)
+	}
+
+	return patchOptions
 }
 
 // ManagedResourceStatus defines the observed state of ManagedResource
 type ManagedResourceStatus struct {
+
+	// Conditions are the latest observations of the managed object state
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []Condition `json:"conditions,omitempty"`
+
+	// ObservedGeneration is the most recent generation reconciled by the operator
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LastSyncTime is the last time the managed object was successfully applied
+	// +optional
+	// +nullable
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// LastError is the message of the last error which occurred during reconciliation
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+
+	// Conflicts are the field ownership conflicts which prevented the last server-side apply
+	// +optional
+	Conflicts []string `json:"conflicts,omitempty"`
+
+	// EnforcedFields are the paths of the fields a persistent overwrite set or deleted when the managed object was last applied
+	// +optional
+	EnforcedFields []string `json:"enforcedFields,omitempty"`
+
+	// Source describes the source contents which were last applied
+	// +optional
+	Source *utils.SourceStatus `json:"source,omitempty"`
+
+	// Objects are references to the live managed objects in the order they are applied
+	// +optional
+	Objects []ManagedObjectStatus `json:"objects,omitempty"`
+
+	// Drift describes out of band changes made to the managed object
+	// +optional
+	Drift *DriftStatus `json:"drift,omitempty"`
+}
+
+// ManagedObjectStatus identifies a live managed object as it was last applied
+type ManagedObjectStatus struct {
+	corev1.ObjectReference `json:",inline"`
+
+	// Generation is the generation of the managed object as it was last applied
+	// +optional
+	Generation int64 `json:"generation,omitempty"`
+
+	// Reason is the outcome of the last attempt to apply the managed object
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message describes the error which occurred during the last attempt to apply the managed object
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Overlay is the binding item whose overlay was enforced on the managed object, as <binding>/items/<index>
+	// +optional
+	Overlay string `json:"overlay,omitempty"`
+}
+
+// Identity uniquely identifies the managed object within the cluster regardless of its API version
+func (s ManagedObjectStatus) Identity() string {
+	return utils.ObjectIdentity(
+		schema.FromAPIVersionAndKind(s.APIVersion, s.Kind).GroupKind(),
+		types.NamespacedName{Namespace: s.Namespace, Name: s.Name},
+	)
+}
+
+// Struct returns a reference to the managed object which bindings are matched against
+func (s ManagedObjectStatus) Struct() *utils.ManagedResourceStruct {
+	return &utils.ManagedResourceStruct{
+		APIVersion: s.APIVersion,
+		Kind:       s.Kind,
+		Metadata: utils.MetadataStruct{
+			Name:      s.Name,
+			Namespace: utils.Namespace(s.Namespace),
+		},
+	}
+}
+
+// ManagedObjects reads the source of the managed resource and returns its overwritten and patched objects in the order they should be applied along with the source status
+func (r *ManagedResource) ManagedObjects(sourceReader client.Reader) ([]utils.ManagedObject, utils.SourceStatus, error) {
+
+	managedObjects, sourceStatus, err := utils.ProcessSource(r.Spec.Source, utils.SourceContext{
+		Client:              sourceReader,
+		Namespace:           r.Namespace,
+		Name:                r.Name,
+		Labels:              r.Labels,
+		Parameters:          r.Spec.Parameters,
+		ParametersConfigMap: r.Spec.ParametersConfigMap,
+	})
+	if err != nil {
+		return nil, utils.SourceStatus{}, err
+	}
+
+	// A persistent overwrite is applied after the patches so the fields it sets are always enforced
+	if r.Spec.OverwriteMode == OverwriteModePersistent {
+		managedObjects, err = utils.PatchObjects(managedObjects, r.Spec.Patches)
+		if err != nil {
+			return nil, utils.SourceStatus{}, err
+		}
+
+		managedObjects, err = utils.OverwriteObjects(managedObjects, r.Spec.Overwrite, r.Spec.OverwriteStrategy)
+		if err != nil {
+			return nil, utils.SourceStatus{}, err
+		}
+
+		return managedObjects, sourceStatus, nil
+	}
+
+	managedObjects, err = utils.OverwriteObjects(managedObjects, r.Spec.Overwrite, r.Spec.OverwriteStrategy)
+	if err != nil {
+		return nil, utils.SourceStatus{}, err
+	}
+
+	managedObjects, err = utils.PatchObjects(managedObjects, r.Spec.Patches)
+	if err != nil {
+		return nil, utils.SourceStatus{}, err
+	}
+
+	return managedObjects, sourceStatus, nil
+}
+
+// EnforcedFields returns the paths of the fields a persistent overwrite enforces, if any
+func (r *ManagedResource) EnforcedFields() ([]string, error) {
+
+	if r.Spec.OverwriteMode != OverwriteModePersistent {
+		return nil, nil
+	}
+
+	return utils.OverwriteFields(r.Spec.Overwrite)
+}
+
+// Requester returns the user who last changed the spec of the managed resource as recorded by the webhook, if any
+func (r *ManagedResource) Requester() *authenticationv1.UserInfo {
+
+	username, ok := r.Annotations[utils.RequesterAnnotation]
+	if !ok {
+		return nil
+	}
+
+	requester := &authenticationv1.UserInfo{Username: username, UID: r.Annotations[utils.RequesterUIDAnnotation]}
+	if groups := r.Annotations[utils.RequesterGroupsAnnotation]; groups != "" {
+		requester.Groups = strings.Split(groups, ",")
+	}
+
+	// A requester whose extra attributes cannot be read is unknown, as they may restrict it
+	if extra := r.Annotations[utils.RequesterExtraAnnotation]; extra != "" {
+		if err := json.Unmarshal([]byte(extra), &requester.Extra); err != nil {
+			return nil
+		}
+	}
+
+	return requester
+}
+
+// SetRequester records the user who changed the spec of the managed resource
+func (r *ManagedResource) SetRequester(requester authenticationv1.UserInfo) {
+
+	if r.Annotations == nil {
+		r.Annotations = make(map[string]string)
+	}
+	r.Annotations[utils.RequesterAnnotation] = requester.Username
+	r.Annotations[utils.RequesterGroupsAnnotation] = strings.Join(requester.Groups, ",")
+
+	delete(r.Annotations, utils.RequesterUIDAnnotation)
+	if requester.UID != "" {
+		r.Annotations[utils.RequesterUIDAnnotation] = requester.UID
+	}
+	delete(r.Annotations, utils.RequesterExtraAnnotation)
+	if len(requester.Extra) != 0 {
+		if extra, err := json.Marshal(requester.Extra); err == nil {
+			r.Annotations[utils.RequesterExtraAnnotation] = string(extra)
+		}
+	}
+}
+
+// ClearRequester removes the recorded requester of the managed resource
+func (r *ManagedResource) ClearRequester() {
+	delete(r.Annotations, utils.RequesterAnnotation)
+	delete(r.Annotations, utils.RequesterGroupsAnnotation)
+	delete(r.Annotations, utils.RequesterUIDAnnotation)
+	delete(r.Annotations, utils.RequesterExtraAnnotation)
+}
+
+// Drift types reported by managed resources
+const (
+	DriftModified = "Modified"
+	DriftDeleted  = "Deleted"
+)
+
+// DriftStatus describes out of band changes made to the managed object
+type DriftStatus struct {
+
+	// Type is the type of the last detected change
+	// +kubebuilder:validation:Enum=Modified;Deleted
+	Type string `json:"type"`
+
+	// LastDriftTime is the last time the managed object was found to be changed out of band
+	LastDriftTime metav1.Time `json:"lastDriftTime"`
+
+	// Count is the number of times the managed object was found to be changed out of band
+	Count int64 `json:"count"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:shortName=mr,scope=Namespaced
-// +kubebuilder:printcolumn:name="Resource name",type=string,JSONPath=`.spec.source.object.metadata.name`
-// +kubebuilder:printcolumn:name="Resource kind",type=string,JSONPath=`.spec.source.object.kind`
-// +kubebuilder:printcolumn:name="Resource namespace",type=string,JSONPath=`.spec.source.object.metadata.namespace`
+// The resource columns show the first live managed object, since sources other than inline objects are only read by the operator
+// +kubebuilder:printcolumn:name="Resource name",type=string,JSONPath=`.status.objects[0].name`
+// +kubebuilder:printcolumn:name="Resource kind",type=string,JSONPath=`.status.objects[0].kind`
+// +kubebuilder:printcolumn:name="Resource namespace",type=string,JSONPath=`.status.objects[0].namespace`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
 
 // ManagedResource is the Schema for the managedresources API
 type ManagedResource struct {
@@ -17,23 +17,30 @@ limitations under the License.
 package v1beta1
 
 import (
-	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
-	"io"
+	"fmt"
+	"net/http"
 	"reflect"
+	"strconv"
 
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
-
-	"github.com/fatih/structs"
-	"github.com/jeremywohl/flatten"
-	"sigs.k8s.io/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
 	"operator/pkg/utils"
 )
@@ -44,17 +51,70 @@ var managedresourcelog = logf.Log.WithName("managedresource-resource")
 // k8sClient is for querying API for dry-runs and bindings
 var k8sClient client.Client = nil
 
-// SetupWebhookWithManager registers webhooks with the controller manager
+// ErrPermissionDenied is returned when no binding permits the requested operation
+var ErrPermissionDenied = errors.New("permission denied")
+
+// SetupWebhookWithManager registers webhooks with the controller manager, which are admission handlers rather than a defaulter and a validator so they see the requesting user
 func (r *ManagedResource) SetupWebhookWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewWebhookManagedBy(mgr).
-		For(r).
-		Complete()
+	mgr.GetWebhookServer().Register("/mutate-paas-il-v1beta1-managedresource", &webhook.Admission{Handler: &managedResourceDefaulter{}})
+	mgr.GetWebhookServer().Register("/validate-paas-il-v1beta1-managedresource", &webhook.Admission{Handler: &managedResourceValidator{}})
+
+	return nil
 }
 
 // +kubebuilder:webhook:path=/mutate-paas-il-v1beta1-managedresource,mutating=true,failurePolicy=fail,groups=paas.il,resources=managedresources,verbs=create;update,versions=v1beta1,name=mmanagedresource.kb.io
 // +kubebuilder:rbac:groups=paas.il,resources=managedresourcebindings,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get
+
+// managedResourceDefaulter inlines the sources of managed resources and records the user who changed them
+type managedResourceDefaulter struct {
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &managedResourceDefaulter{}
+
+// InjectDecoder implements admission.DecoderInjector
+func (d *managedResourceDefaulter) InjectDecoder(decoder *admission.Decoder) error {
+	d.decoder = decoder
+	return nil
+}
+
+// Handle implements admission.Handler
+func (d *managedResourceDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+
+	r := &ManagedResource{}
+	if err := d.decoder.Decode(req, r); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
 
-var _ webhook.Defaulter = &ManagedResource{}
+	// Inline the source and record the requester whenever the spec changes, keeping the recorded requester on other updates such as finalizers being added
+	if req.Operation == admissionv1beta1.Update {
+		oldManagedResource := &ManagedResource{}
+		if err := d.decoder.DecodeRaw(req.OldObject, oldManagedResource); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+
+		if reflect.DeepEqual(r.Spec, oldManagedResource.Spec) {
+			r.ClearRequester()
+			if requester := oldManagedResource.Requester(); requester != nil {
+				r.SetRequester(*requester)
+			}
+		} else {
+			r.Default()
+			r.SetRequester(req.UserInfo)
+		}
+	} else {
+		r.Default()
+		r.SetRequester(req.UserInfo)
+	}
+
+	managedResourceBytes, err := json.Marshal(r)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	return admission.PatchResponseFromRaw(req.Object.Raw, managedResourceBytes)
+}
 
 func getClient() client.Client {
 
@@ -65,6 +125,12 @@ func getClient() client.Client {
 		if err := AddToScheme(scheme); err != nil {
 			panic(err)
 		}
+		if err := corev1.AddToScheme(scheme); err != nil {
+			panic(err)
+		}
+		if err := authorizationv1.AddToScheme(scheme); err != nil {
+			panic(err)
+		}
 
 		// Init kubernetes client
 		k8sClient, _ = client.New(ctrl.GetConfigOrDie(), client.Options{
@@ -75,7 +141,49 @@ func getClient() client.Client {
 	return k8sClient
 }
 
-func checkPermissions(r *utils.ManagedResourceStruct, crNamespace utils.Namespace, verb utils.Verb) error {
+// PermissionGrant identifies the binding item which permits an operation on an object
+type PermissionGrant struct {
+
+	// Binding is the name of the binding
+	Binding string
+
+	// Item is the index of the item within the binding
+	Item int
+
+	// Overlay is enforced on the object by the binding item
+	Overlay runtime.RawExtension
+}
+
+// String returns the binding item as <binding>/items/<index>
+func (g PermissionGrant) String() string {
+	return g.Binding + "/items/" + strconv.Itoa(g.Item)
+}
+
+// ApplyOverlay merges the overlay of the binding item into the object as a JSON merge patch, which may not change the identity of the object
+func (g PermissionGrant) ApplyOverlay(managedObject utils.ManagedObject) (utils.ManagedObject, error) {
+
+	if g.Overlay.Raw == nil {
+		return managedObject, nil
+	}
+
+	overlaidObjectBytes, err := utils.ProcessOverwrite(managedObject.Bytes, g.Overlay, utils.OverwriteStrategyJSONMerge)
+	if err != nil {
+		return utils.ManagedObject{}, errors.New("an error occurred while applying overlay of " + g.String() + ": " + err.Error())
+	}
+
+	overlaidObject, err := utils.ProcessObject(overlaidObjectBytes)
+	if err != nil {
+		return utils.ManagedObject{}, errors.New("an error occurred while applying overlay of " + g.String() + ": " + err.Error())
+	} else if overlaidObject.Identity() != managedObject.Identity() {
+		return utils.ManagedObject{}, errors.New("an error occurred while applying overlay of " + g.String() + ": overlay may not change the API version, kind, name or namespace of the object")
+	}
+
+	return overlaidObject, nil
+}
+
+// CheckPermissions ensures that a namespace and the requesting user are allowed to perform the verb on the object by any of the bindings and returns the binding item which permits it,
+// the object content is checked against the constraints of the binding items unless it is nil
+func CheckPermissions(r *utils.ManagedResourceStruct, object runtime.Object, crNamespace utils.Namespace, requester *authenticationv1.UserInfo, verb utils.Verb) (PermissionGrant, error) {
 
 	// 'contains' function for string slices
 	contains := func(list interface{}, match interface{}) bool {
@@ -90,82 +198,161 @@ func checkPermissions(r *utils.ManagedResourceStruct, crNamespace utils.Namespac
 		return false
 	}
 
-	// Get flat map from target struct
-	targetMap, err := flatten.Flatten(structs.Map(r), "", flatten.DotStyle)
-	if err != nil {
-		return err
-	}
-
 	// List all bindings
 	bindings := &ManagedResourceBindingList{}
 	if err := getClient().List(context.Background(), bindings, &client.ListOptions{}); err != nil {
-		return err
+		return PermissionGrant{}, err
 	}
 
-	// Iterate bindings
+	// Read the namespace labels if any binding selects namespaces by their labels
+	var namespaceLabels labels.Set
 	for _, binding := range bindings.Items {
+		if binding.Spec.NamespaceSelector != nil {
+			namespace := &corev1.Namespace{}
+			if err := getClient().Get(context.Background(), client.ObjectKey{Name: string(crNamespace)}, namespace); err != nil {
+				return PermissionGrant{}, errors.New("an error occurred while reading namespace " + string(crNamespace) + ": " + err.Error())
+			}
+			namespaceLabels = namespace.Labels
+			break
+		}
+	}
 
-		if contains(binding.Spec.Namespaces, crNamespace) || contains(binding.Spec.Namespaces, "*") {
+	// Iterate bindings, remembering the first item which only denies because of its constraints
+	var violation error
+	for _, binding := range bindings.Items {
+
+		if binding.matchesNamespace(crNamespace, namespaceLabels) && binding.matchesRequester(requester) {
 
 			// Check if object is present
-			for _, item := range binding.Spec.Items {
+			for itemIndex, item := range binding.Spec.Items {
 
-				// Get flat map from object struct
-				objectMap, err := flatten.Flatten(structs.Map(item.Object), "", flatten.DotStyle)
-				if err != nil {
-					return err
+				if !contains(item.Verbs, verb) {
+					continue
 				}
 
 				// Find matching object
-				match := true
-				for key, value := range objectMap {
-					if value != "*" && value != targetMap[key] {
-						match = false
-						break
+				match, err := matchesObject(item.Object, r)
+				if err != nil {
+					return PermissionGrant{}, err
+				} else if !match {
+					continue
+				}
+
+				// Ensure the object content satisfies the item constraints
+				grant := PermissionGrant{Binding: binding.Name, Item: itemIndex, Overlay: item.Overlay}
+				if item.Constraints != nil && object != nil {
+					if err := item.Constraints.Check(object); err != nil {
+						var constraintViolation *ConstraintViolation
+						if !errors.As(err, &constraintViolation) {
+							return PermissionGrant{}, err
+						}
+						if violation == nil {
+							violation = fmt.Errorf("%w: %s violates constraints of %s: %s", ErrPermissionDenied, constraintViolation.Path, grant, constraintViolation.Message)
+						}
+						continue
 					}
 				}
 
-				// Allow if match and verb are found
-				if match && contains(item.Verbs, verb) {
-					return nil
+				// Ensure the requester holds the use verb on the binding if it requires it
+				if binding.Spec.RequireUse {
+					allowed, err := canUseBinding(binding.Name, requester)
+					if err != nil {
+						return PermissionGrant{}, err
+					} else if !allowed {
+						continue
+					}
 				}
+
+				// Allow if match and verb are found
+				return grant, nil
 			}
 		}
 	}
 
-	return errors.New("permission denied")
+	if violation != nil {
+		return PermissionGrant{}, violation
+	}
+
+	return PermissionGrant{}, ErrPermissionDenied
 }
 
-// Default implements webhook.Defaulter so a webhook will be registered for the type
+// CheckApplyPermissions ensures the requesting user may apply an object and returns the binding item which permits it: an object which does not exist yet must be creatable,
+// while an existing one must be updatable, or creatable in which case it is left as it is since it is write-once
+func CheckApplyPermissions(managedObject utils.ManagedObject, crNamespace utils.Namespace, requester *authenticationv1.UserInfo, exists bool) (PermissionGrant, error) {
+
+	if !exists {
+		return CheckPermissions(managedObject.Struct, managedObject.Object, crNamespace, requester, utils.VerbCreate)
+	}
+
+	grant, err := CheckPermissions(managedObject.Struct, managedObject.Object, crNamespace, requester, utils.VerbUpdate)
+	if errors.Is(err, ErrPermissionDenied) {
+		if createGrant, createErr := CheckPermissions(managedObject.Struct, managedObject.Object, crNamespace, requester, utils.VerbCreate); createErr == nil {
+			return createGrant, nil
+		}
+	}
+
+	return grant, err
+}
+
+// ObjectExists checks whether a managed object exists in the cluster, an object of a kind which is not defined yet does not
+func ObjectExists(c client.Client, managedObject utils.ManagedObject) (bool, error) {
+
+	clusterObject := managedObject.Object.DeepCopyObject()
+	if err := c.Get(context.Background(), managedObject.Key, clusterObject); err != nil {
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// objectError prefixes an error with the object it occurred for
+func objectError(managedObject utils.ManagedObject, err error) error {
+	return fmt.Errorf("%s: %w", managedObject, err)
+}
+
+// isUndefinedKind checks whether an error occurred because the object kind is only defined by another object of the source
+func isUndefinedKind(err error, managedObject utils.ManagedObject, managedObjects []utils.ManagedObject) bool {
+	return meta.IsNoMatchError(err) && utils.DefinesKind(managedObjects, managedObject.Object.GetObjectKind().GroupVersionKind())
+}
+
+// Default inlines the source of the managed resource unless it is live
 func (r *ManagedResource) Default() {
 	managedresourcelog.Info("default", "name", r.Name)
 
-	// Get managed resource bytes
-	managedResourceBytes, _, _, _, err := utils.ProcessSource(r.Spec.Source)
-	if err != nil {
+	// Live sources are read by the operator on every reconciliation, as are URL sources once signatures are required so they are verified every time
+	if r.Spec.Source.IsLive() || utils.SignaturesRequired() {
 		return
 	}
 
-	// Overwrite metadata fields if overwrite is present
-	managedResourceBytes, err = utils.ProcessOverwrite(managedResourceBytes, r.Spec.Overwrite)
+	// Get managed objects with their fields overwritten and patched if overwrite or patches are present
+	managedObjects, _, err := r.ManagedObjects(getClient())
 	if err != nil {
 		return
 	}
+	managedObjectsBytes := make([][]byte, 0, len(managedObjects))
+	for _, managedObject := range managedObjects {
+		managedObjectsBytes = append(managedObjectsBytes, managedObject.Bytes)
+	}
 
-	// Empty overwrite field
-	r.Spec.Overwrite.Raw = nil
+	// Empty overwrite, patches and parameters fields, which are embedded in the managed objects,
+	// keeping a persistent overwrite so it is applied again on every update
+	if r.Spec.OverwriteMode != OverwriteModePersistent {
+		r.Spec.Overwrite.Raw = nil
+		r.Spec.OverwriteStrategy = ""
+	}
+	r.Spec.Patches = nil
+	r.Spec.Parameters = nil
+	r.Spec.ParametersConfigMap = ""
 
-	// Convert YAML bytes to JSON bytes for raw extension
-	managedResourceBytesJSON, err := yaml.YAMLToJSON(managedResourceBytes)
+	// New raw object struct with managed objects
+	objectSource, err := utils.InlineObjects(managedObjectsBytes)
 	if err != nil {
 		return
 	}
 
-	// New raw object struct with managed resource bytes
-	objectSource := runtime.RawExtension{
-		Raw: managedResourceBytesJSON,
-	}
-
 	// New source struct with only the Object field defined
 	r.Spec.Source = utils.SourceStruct{
 		Object: objectSource,
@@ -174,28 +361,63 @@ func (r *ManagedResource) Default() {
 
 // +kubebuilder:webhook:verbs=create;update;delete,path=/validate-paas-il-v1beta1-managedresource,mutating=false,failurePolicy=fail,groups=paas.il,resources=managedresources,versions=v1beta1,name=vmanagedresource.kb.io
 
-var _ webhook.Validator = &ManagedResource{}
+// managedResourceValidator ensures the requesting user is permitted to change the objects of managed resources and that they can be applied
+type managedResourceValidator struct {
+	decoder *admission.Decoder
+}
 
-// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
-func (r *ManagedResource) ValidateCreate() error {
-	managedresourcelog.Info("validate create", "name", r.Name)
+var _ admission.DecoderInjector = &managedResourceValidator{}
 
-	// Process object source
-	_, newManagedResourceStruct, newManagedObject, newManagedObjectKey, err := utils.ProcessSource(r.Spec.Source)
-	if err != nil {
-		return err
+// InjectDecoder implements admission.DecoderInjector
+func (v *managedResourceValidator) InjectDecoder(decoder *admission.Decoder) error {
+	v.decoder = decoder
+	return nil
+}
+
+// Handle implements admission.Handler
+func (v *managedResourceValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+
+	r := &ManagedResource{}
+	var err error
+	switch req.Operation {
+	case admissionv1beta1.Create:
+		if err := v.decoder.Decode(req, r); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		err = r.validateCreate(&req.UserInfo)
+	case admissionv1beta1.Update:
+		oldManagedResource := &ManagedResource{}
+		if err := v.decoder.Decode(req, r); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if err := v.decoder.DecodeRaw(req.OldObject, oldManagedResource); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		err = r.validateUpdate(oldManagedResource, &req.UserInfo)
+	case admissionv1beta1.Delete:
+		if err := v.decoder.DecodeRaw(req.OldObject, r); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		err = r.validateDelete(&req.UserInfo)
 	}
 
-	// Check for creation permission
-	if err := checkPermissions(newManagedResourceStruct, utils.Namespace(r.Namespace), utils.VerbCreate); err != nil {
-		return err
+	if err != nil {
+		return admission.Denied(err.Error())
 	}
 
+	return admission.Allowed("")
+}
+
+// validateCreateObject ensures that a new object can be created
+func (r *ManagedResource) validateCreateObject(managedObject utils.ManagedObject, managedObjects []utils.ManagedObject) error {
+
 	// Try getting object from cluster
-	clusterObject := newManagedObject.DeepCopyObject()
-	if err := getClient().Get(context.Background(), newManagedObjectKey, clusterObject); err != nil {
+	clusterObject := managedObject.Object.DeepCopyObject()
+	if err := getClient().Get(context.Background(), managedObject.Key, clusterObject); err != nil {
 
-		if !apierrors.IsNotFound(err) {
+		if isUndefinedKind(err, managedObject, managedObjects) {
+			return nil
+		} else if !apierrors.IsNotFound(err) {
 			return err
 		}
 
@@ -206,93 +428,239 @@ func (r *ManagedResource) ValidateCreate() error {
 	// -- Ensure there are no other errors during creation --
 
 	// Try dry-run creation
-	if err := getClient().Create(context.Background(), newManagedObject, &client.CreateOptions{
+	if err := getClient().Create(context.Background(), managedObject.Object.DeepCopyObject(), &client.CreateOptions{
 		DryRun: []string{"All"},
-	}); err != nil {
+	}); err != nil && !isUndefinedKind(err, managedObject, managedObjects) {
 		return err
 	}
 
 	return nil
 }
 
-// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
-func (r *ManagedResource) ValidateUpdate(old runtime.Object) error {
-	managedresourcelog.Info("validate update", "name", r.Name)
+// validateUpdateObject ensures that an existing object can be updated
+func (r *ManagedResource) validateUpdateObject(managedObject utils.ManagedObject, managedObjects []utils.ManagedObject) error {
+
+	newManagedObject := managedObject.Object.DeepCopyObject()
+
+	// Try dry-run server-side apply
+	if r.Spec.Apply.Mode == ApplyModeServerSide {
+		if err := getClient().Patch(context.Background(), newManagedObject, client.Apply, append(r.Spec.Apply.PatchOptions(), client.DryRunAll)...); err != nil && !isUndefinedKind(err, managedObject, managedObjects) {
+			return err
+		}
 
-	// Skip validation if resource is being deleted
-	if !r.DeletionTimestamp.IsZero() {
 		return nil
 	}
 
-	// Old CR bytes
-	var oldManagedResourceBytesBuffer bytes.Buffer
-	if err := utils.ObjectSerializer.Encode(old, io.Writer(&oldManagedResourceBytesBuffer)); err != nil {
+	// Get the old object's resource version and set it for the new object
+	oldManagedObject := newManagedObject.DeepCopyObject()
+	if err := getClient().Get(context.Background(), managedObject.Key, oldManagedObject); err != nil {
 		return err
 	}
+	newManagedObject.(controllerutil.Object).SetResourceVersion(oldManagedObject.(controllerutil.Object).GetResourceVersion())
 
-	// Old CR
-	oldManagedResource := &ManagedResource{}
-	if err := yaml.Unmarshal(oldManagedResourceBytesBuffer.Bytes(), oldManagedResource); err != nil {
+	// Try dry-run update
+	if err := getClient().Update(context.Background(), newManagedObject, &client.UpdateOptions{
+		DryRun: []string{"All"},
+	}); err != nil {
 		return err
 	}
 
-	// Process both objects
-	_, oldManagedResourceStruct, _, oldManagedObjectKey, err := utils.ProcessSource(oldManagedResource.Spec.Source)
+	return nil
+}
+
+// enforceOverlays applies the overlays of the binding items which permit applying the objects, the same way the operator does when applying them
+func (r *ManagedResource) enforceOverlays(managedObjects []utils.ManagedObject, requester *authenticationv1.UserInfo) error {
+
+	for i, managedObject := range managedObjects {
+
+		exists, err := ObjectExists(getClient(), managedObject)
+		if err != nil {
+			return objectError(managedObject, err)
+		}
+
+		// Objects which may not be applied are left without an overlay
+		grant, err := CheckApplyPermissions(managedObject, utils.Namespace(r.Namespace), requester, exists)
+		if errors.Is(err, ErrPermissionDenied) {
+			continue
+		} else if err != nil {
+			return objectError(managedObject, err)
+		}
+
+		if managedObjects[i], err = grant.ApplyOverlay(managedObject); err != nil {
+			return objectError(managedObject, err)
+		}
+		if grant.Overlay.Raw != nil {
+			managedresourcelog.Info("overlay applied", "name", r.Name, "object", managedObject.String(), "binding", grant.String())
+		}
+	}
+
+	return nil
+}
+
+// validateCreate ensures the requesting user may create the objects of a new managed resource
+func (r *ManagedResource) validateCreate(requester *authenticationv1.UserInfo) error {
+	managedresourcelog.Info("validate create", "name", r.Name)
+
+	// Process object source
+	newManagedObjects, _, err := r.ManagedObjects(getClient())
 	if err != nil {
 		return err
 	}
-	_, newManagedResourceStruct, newManagedObject, _, err := utils.ProcessSource(r.Spec.Source)
+
+	// Check for creation permission of each object
+	for _, newManagedObject := range newManagedObjects {
+		if _, err := CheckPermissions(newManagedObject.Struct, newManagedObject.Object, utils.Namespace(r.Namespace), requester, utils.VerbCreate); err != nil {
+			return objectError(newManagedObject, err)
+		}
+	}
+
+	// Validate the objects as the operator applies them
+	if err := r.enforceOverlays(newManagedObjects, requester); err != nil {
+		return err
+	}
+
+	// Ensure each object can be created
+	for _, newManagedObject := range newManagedObjects {
+		if err := r.validateCreateObject(newManagedObject, newManagedObjects); err != nil {
+			return objectError(newManagedObject, err)
+		}
+	}
+
+	return nil
+}
+
+// validateUpdate ensures the requesting user may create, update and delete the objects which changed between the old and the updated managed resource
+func (r *ManagedResource) validateUpdate(oldManagedResource *ManagedResource, requester *authenticationv1.UserInfo) error {
+	managedresourcelog.Info("validate update", "name", r.Name)
+
+	// Skip validation if resource is being deleted
+	if !r.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	// Skip validation if the objects are unchanged, such as when labels or finalizers are changed
+	if reflect.DeepEqual(r.Spec, oldManagedResource.Spec) {
+		return nil
+	}
+
+	// Process both sources, falling back to the objects recorded in the status if the old source can no longer be read
+	oldManagedObjects, _, err := oldManagedResource.ManagedObjects(getClient())
+	if err != nil {
+		managedresourcelog.Info("reading recorded objects", "name", r.Name, "reason", err.Error())
+		oldManagedObjects = oldManagedResource.recordedObjects()
+	}
+	newManagedObjects, _, err := r.ManagedObjects(getClient())
 	if err != nil {
 		return err
 	}
 
-	// Ensure that the structs are equal
-	if !reflect.DeepEqual(newManagedResourceStruct, oldManagedResourceStruct) {
-		return errors.New("new managed resource must manage the same object as the old managed resource")
+	// Map old objects by their identity
+	oldManagedObjectsMap := make(map[string]utils.ManagedObject)
+	for _, oldManagedObject := range oldManagedObjects {
+		oldManagedObjectsMap[oldManagedObject.Identity()] = oldManagedObject
+	}
+
+	// Check for creation permission of added objects and update permission of changed objects
+	for _, newManagedObject := range newManagedObjects {
+		oldManagedObject, exists := oldManagedObjectsMap[newManagedObject.Identity()]
+
+		if !exists {
+			if _, err := CheckPermissions(newManagedObject.Struct, newManagedObject.Object, utils.Namespace(r.Namespace), requester, utils.VerbCreate); err != nil {
+				return objectError(newManagedObject, err)
+			}
+		} else if !reflect.DeepEqual(newManagedObject.Object, oldManagedObject.Object) {
+			if _, err := CheckPermissions(newManagedObject.Struct, newManagedObject.Object, utils.Namespace(r.Namespace), requester, utils.VerbUpdate); err != nil {
+				return objectError(newManagedObject, err)
+			}
+		}
+	}
+
+	// Check for deletion permission of removed objects
+	newManagedObjectsMap := make(map[string]bool)
+	for _, newManagedObject := range newManagedObjects {
+		newManagedObjectsMap[newManagedObject.Identity()] = true
+	}
+	for _, oldManagedObject := range oldManagedObjects {
+		if !newManagedObjectsMap[oldManagedObject.Identity()] {
+			if _, err := CheckPermissions(oldManagedObject.Struct, nil, utils.Namespace(r.Namespace), requester, utils.VerbDelete); err != nil {
+				return objectError(oldManagedObject, err)
+			}
+		}
 	}
 
 	// -- Ensure there are no other errors during update --
 
-	// Get the old object's resource version and set it for the new object
-	oldManagedObject := newManagedObject.DeepCopyObject()
-	if err := getClient().Get(context.Background(), oldManagedObjectKey, oldManagedObject); err != nil {
+	// Validate the objects as the operator applies them
+	if err := r.enforceOverlays(newManagedObjects, requester); err != nil {
 		return err
 	}
-	newManagedObject.(controllerutil.Object).SetResourceVersion(oldManagedObject.(controllerutil.Object).GetResourceVersion())
 
-	// Try dry-run update
-	if err := getClient().Update(context.Background(), newManagedObject, &client.UpdateOptions{
-		DryRun: []string{"All"},
-	}); err != nil {
-		return err
+	for _, newManagedObject := range newManagedObjects {
+
+		// Objects which were added to the source are validated as new objects
+		validateObject := r.validateUpdateObject
+		if _, exists := oldManagedObjectsMap[newManagedObject.Identity()]; !exists {
+			validateObject = r.validateCreateObject
+		}
+
+		if err := validateObject(newManagedObject, newManagedObjects); err != nil {
+			return objectError(newManagedObject, err)
+		}
 	}
 
 	return nil
 }
 
-// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
-func (r *ManagedResource) ValidateDelete() error {
+// validateDelete ensures the requesting user may delete the objects of a managed resource
+func (r *ManagedResource) validateDelete(requester *authenticationv1.UserInfo) error {
 	managedresourcelog.Info("validate delete", "name", r.Name)
 
-	// Process given object
-	_, managedResourceStruct, managedObject, _, err := utils.ProcessSource(r.Spec.Source)
+	// Process given source, falling back to the objects recorded in the status if it can no longer be read
+	managedObjects, _, err := r.ManagedObjects(getClient())
 	if err != nil {
-		return err
+		managedresourcelog.Info("reading recorded objects", "name", r.Name, "reason", err.Error())
+		managedObjects = r.recordedObjects()
 	}
 
-	// Check deletion permissions
-	if err := checkPermissions(managedResourceStruct, utils.Namespace(r.Namespace), utils.VerbDelete); err != nil {
-		return err
+	// Check deletion permissions of each object
+	for _, managedObject := range managedObjects {
+		if _, err := CheckPermissions(managedObject.Struct, nil, utils.Namespace(r.Namespace), requester, utils.VerbDelete); err != nil {
+			return objectError(managedObject, err)
+		}
 	}
 
 	// -- Ensure there are no other errors during deletion --
 
-	// Try dry-run deletion
-	if err := getClient().Delete(context.Background(), managedObject, &client.DeleteOptions{
-		DryRun: []string{"All"},
-	}); err != nil && !apierrors.IsNotFound(err) {
-		return err
+	// Try dry-run deletion of each object
+	for _, managedObject := range managedObjects {
+		if err := getClient().Delete(context.Background(), managedObject.Object.DeepCopyObject(), &client.DeleteOptions{
+			DryRun: []string{"All"},
+		}); err != nil && !apierrors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+			return objectError(managedObject, err)
+		}
 	}
 
 	return nil
 }
+
+// recordedObjects returns references to the objects the operator last recorded as applied in the status
+func (r *ManagedResource) recordedObjects() []utils.ManagedObject {
+
+	managedObjects := make([]utils.ManagedObject, 0, len(r.Status.Objects))
+	for _, objectStatus := range r.Status.Objects {
+
+		object := &unstructured.Unstructured{}
+		object.SetAPIVersion(objectStatus.APIVersion)
+		object.SetKind(objectStatus.Kind)
+		object.SetNamespace(objectStatus.Namespace)
+		object.SetName(objectStatus.Name)
+
+		managedObjects = append(managedObjects, utils.ManagedObject{
+			Struct: objectStatus.Struct(),
+			Object: object,
+			Key:    types.NamespacedName{Namespace: objectStatus.Namespace, Name: objectStatus.Name},
+		})
+	}
+
+	return managedObjects
+}
@@ -0,0 +1,177 @@
+package v1beta1
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"operator/pkg/utils"
+)
+
+// useFakeClient replaces the client of the webhook with a fake client holding the objects until the returned function is called
+func useFakeClient(t *testing.T, objects ...runtime.Object) func() {
+
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := k8sClient
+	k8sClient = fake.NewFakeClientWithScheme(scheme, objects...)
+
+	return func() { k8sClient = previous }
+}
+
+func TestValidateUpdateUnreadableOldSource(t *testing.T) {
+
+	settings := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "tenant", Name: "settings"}, Data: map[string]string{"mode": "a"}}
+	binding := func(verb utils.Verb) *ManagedResourceBinding {
+		return &ManagedResourceBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "settings"},
+			Spec: ManagedResourceBindingSpec{
+				Namespaces: []utils.Namespace{"tenant"},
+				Items: []ManagedResourceBindingItem{{
+					Object: utils.ManagedResourceStruct{Kind: "ConfigMap", Metadata: utils.MetadataStruct{Name: "settings", Namespace: "tenant"}},
+					Verbs:  []utils.Verb{verb},
+				}},
+			},
+		}
+	}
+
+	// The old source references a deleted config map, its objects are only known from the status
+	oldManagedResource := &ManagedResource{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "tenant", Name: "settings"},
+		Spec: ManagedResourceSpec{
+			Source: utils.SourceStruct{ConfigMapRef: &utils.SourceReference{Name: "deleted", Key: "object.yaml"}},
+		},
+		Status: ManagedResourceStatus{
+			Objects: []ManagedObjectStatus{{ObjectReference: corev1.ObjectReference{APIVersion: "v1", Kind: "ConfigMap", Namespace: "tenant", Name: "settings"}}},
+		},
+	}
+	updated := oldManagedResource.DeepCopy()
+	updated.Spec.Source = utils.SourceStruct{Object: runtime.RawExtension{Raw: []byte(`{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"settings","namespace":"tenant"},"data":{"mode":"b"}}`)}}
+	relabeled := oldManagedResource.DeepCopy()
+	relabeled.Labels = map[string]string{"team": "payments"}
+
+	tests := []struct {
+		name            string
+		managedResource *ManagedResource
+		verb            utils.Verb
+		wantErr         error
+	}{
+		{name: "metadata update", managedResource: relabeled, verb: utils.VerbDelete},
+		{name: "new source", managedResource: updated, verb: utils.VerbUpdate},
+		{name: "new source without update permission", managedResource: updated, verb: utils.VerbDelete, wantErr: ErrPermissionDenied},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer useFakeClient(t, settings.DeepCopy(), binding(test.verb))()
+
+			err := test.managedResource.validateUpdate(oldManagedResource, nil)
+			if test.wantErr == nil && err != nil {
+				t.Errorf("validateUpdate() error = %v", err)
+			} else if test.wantErr != nil && !errors.Is(err, test.wantErr) {
+				t.Errorf("validateUpdate() error = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckApplyPermissions(t *testing.T) {
+
+	existing := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "tenant", Name: "settings"}}
+	managedObject := utils.ManagedObject{
+		Struct: &utils.ManagedResourceStruct{APIVersion: "v1", Kind: "ConfigMap", Metadata: utils.MetadataStruct{Name: "settings", Namespace: "tenant"}},
+		Object: &corev1.ConfigMap{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{Namespace: "tenant", Name: "settings"},
+		},
+		Key: types.NamespacedName{Namespace: "tenant", Name: "settings"},
+	}
+
+	// Existing objects only need to be updatable, or creatable if they are write-once, while new objects must be creatable
+	tests := map[string]struct {
+		verbs   []utils.Verb
+		exists  bool
+		wantErr error
+	}{
+		"create new":           {verbs: []utils.Verb{utils.VerbCreate}},
+		"update new":           {verbs: []utils.Verb{utils.VerbUpdate}, wantErr: ErrPermissionDenied},
+		"update existing":      {verbs: []utils.Verb{utils.VerbUpdate}, exists: true},
+		"write-once existing":  {verbs: []utils.Verb{utils.VerbCreate}, exists: true},
+		"delete only existing": {verbs: []utils.Verb{utils.VerbDelete}, exists: true, wantErr: ErrPermissionDenied},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			objects := []runtime.Object{&ManagedResourceBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "settings"},
+				Spec: ManagedResourceBindingSpec{
+					Namespaces: []utils.Namespace{"tenant"},
+					Items:      []ManagedResourceBindingItem{{Object: *managedObject.Struct, Verbs: test.verbs}},
+				},
+			}}
+			if test.exists {
+				objects = append(objects, existing.DeepCopy())
+			}
+			defer useFakeClient(t, objects...)()
+
+			exists, err := ObjectExists(getClient(), managedObject)
+			if err != nil {
+				t.Fatal(err)
+			} else if exists != test.exists {
+				t.Fatalf("ObjectExists() = %v, want %v", exists, test.exists)
+			}
+
+			_, err = CheckApplyPermissions(managedObject, "tenant", nil, exists)
+			if test.wantErr == nil && err != nil {
+				t.Errorf("CheckApplyPermissions() error = %v", err)
+			} else if test.wantErr != nil && !errors.Is(err, test.wantErr) {
+				t.Errorf("CheckApplyPermissions() error = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequester(t *testing.T) {
+
+	requester := authenticationv1.UserInfo{
+		Username: "jane",
+		UID:      "1234",
+		Groups:   []string{"developers", "system:authenticated"},
+		Extra:    map[string]authenticationv1.ExtraValue{"scopes.authorization.openshift.io": {"user:info"}},
+	}
+
+	managedResource := &ManagedResource{}
+	managedResource.SetRequester(requester)
+	if recorded := managedResource.Requester(); recorded == nil || !reflect.DeepEqual(*recorded, requester) {
+		t.Errorf("Requester() = %v, want %v", recorded, requester)
+	}
+
+	// A later requester without a UID or extra attributes does not inherit them
+	managedResource.SetRequester(authenticationv1.UserInfo{Username: "john"})
+	if recorded := managedResource.Requester(); recorded == nil || recorded.UID != "" || recorded.Extra != nil {
+		t.Errorf("Requester() = %v, want john only", recorded)
+	}
+
+	managedResource.Annotations[utils.RequesterExtraAnnotation] = "{"
+	if recorded := managedResource.Requester(); recorded != nil {
+		t.Errorf("Requester() = %v, want nil for unreadable extra attributes", recorded)
+	}
+
+	managedResource.ClearRequester()
+	if len(managedResource.Annotations) != 0 {
+		t.Errorf("expected no requester annotations, got %v", managedResource.Annotations)
+	}
+}
@@ -17,7 +17,9 @@ limitations under the License.
 package v1beta1
 
 import (
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 
 	"operator/pkg/utils"
 )
@@ -28,6 +30,37 @@ type ManagedResourceBindingItem struct {
 
 	// +kubebuilder:validation:MinItems=1
 	Verbs []utils.Verb `json:"verbs"`
+
+	// Overlay is merged as a JSON merge patch into every object the item permits to create, after the source and overwrite of the managed resource
+	// +kubebuilder:validation:XPreserveUnknownFields
+	// +nullable
+	// +optional
+	Overlay runtime.RawExtension `json:"overlay,omitempty"`
+
+	// Constraints restrict the content of the objects the item permits to create and update
+	// +optional
+	Constraints *ObjectConstraints `json:"constraints,omitempty"`
+}
+
+// ObjectConstraints restrict the content of the objects a binding item permits
+type ObjectConstraints struct {
+
+	// Schema is an OpenAPI v3 schema the objects must be valid against
+	// +kubebuilder:validation:XPreserveUnknownFields
+	// +optional
+	Schema *runtime.RawExtension `json:"schema,omitempty"`
+
+	// AllowedFields are the dot separated paths of the fields objects may set, where * matches any single field, apiVersion, kind and the name and namespace are always allowed
+	// +optional
+	AllowedFields []string `json:"allowedFields,omitempty"`
+
+	// ForbiddenFields are the dot separated paths of the fields objects must not set, where * matches any single field
+	// +optional
+	ForbiddenFields []string `json:"forbiddenFields,omitempty"`
+
+	// RequiredLabels are the labels objects must have, where an empty value allows any value
+	// +optional
+	RequiredLabels map[string]string `json:"requiredLabels,omitempty"`
 }
 
 // ManagedResourceBindingSpec defines the desired state of ManagedResourceBinding
@@ -36,8 +69,21 @@ type ManagedResourceBindingSpec struct {
 	// +kubebuilder:validation:MinItems=1
 	Items []ManagedResourceBindingItem `json:"items"`
 
-	// +kubebuilder:validation:MinItems=1
-	Namespaces []utils.Namespace `json:"namespaces"`
+	// Namespaces are the names of the namespaces whose managed resources the binding applies to
+	// +optional
+	Namespaces []utils.Namespace `json:"namespaces,omitempty"`
+
+	// NamespaceSelector selects additional namespaces by their labels whose managed resources the binding applies to
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Subjects are the users, groups and service accounts whose changes to managed resources the binding applies to, it applies to any user if none are set
+	// +optional
+	Subjects []rbacv1.Subject `json:"subjects,omitempty"`
+
+	// RequireUse only applies the binding to users who hold the use verb on it through RBAC
+	// +optional
+	RequireUse bool `json:"requireUse,omitempty"`
 }
 
 // ManagedResourceBindingStatus defines the observed state of ManagedResourceBinding
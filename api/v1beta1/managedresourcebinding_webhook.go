@@ -0,0 +1,187 @@
+/*
+Copyright 2020 Vladislav Poberezhny.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"errors"
+	"fmt"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"operator/pkg/utils"
+)
+
+// serviceAccountPrefix is the prefix of the user names service accounts authenticate as
+const serviceAccountPrefix = "system:serviceaccount:"
+
+// log is for logging in this package.
+var managedresourcebindinglog = logf.Log.WithName("managedresourcebinding-resource")
+
+// SetupWebhookWithManager registers webhooks with the controller manager
+func (r *ManagedResourceBinding) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-paas-il-v1beta1-managedresourcebinding,mutating=false,failurePolicy=fail,groups=paas.il,resources=managedresourcebindings,versions=v1beta1,name=vmanagedresourcebinding.kb.io
+
+var _ webhook.Validator = &ManagedResourceBinding{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *ManagedResourceBinding) ValidateCreate() error {
+	managedresourcebindinglog.Info("validate create", "name", r.Name)
+
+	return r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *ManagedResourceBinding) ValidateUpdate(old runtime.Object) error {
+	managedresourcebindinglog.Info("validate update", "name", r.Name)
+
+	return r.validate()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *ManagedResourceBinding) ValidateDelete() error {
+	return nil
+}
+
+// validate ensures the patterns and constraints of the binding can be evaluated, so a binding cannot break the admission of managed resources
+func (r *ManagedResourceBinding) validate() error {
+
+	if len(r.Spec.Namespaces) == 0 && r.Spec.NamespaceSelector == nil {
+		return errors.New("spec: either namespaces or namespaceSelector is required")
+	}
+	if r.Spec.NamespaceSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(r.Spec.NamespaceSelector); err != nil {
+			return errors.New("spec.namespaceSelector: " + err.Error())
+		}
+	}
+
+	for i, subject := range r.Spec.Subjects {
+		switch subject.Kind {
+		case rbacv1.UserKind, rbacv1.GroupKind:
+		case rbacv1.ServiceAccountKind:
+			if subject.Namespace == "" {
+				return fmt.Errorf("spec.subjects[%d].namespace: namespace is required for service accounts", i)
+			}
+		default:
+			return fmt.Errorf("spec.subjects[%d].kind: unknown kind %s, must be User, Group or ServiceAccount", i, subject.Kind)
+		}
+		if subject.Name == "" {
+			return fmt.Errorf("spec.subjects[%d].name: name is required", i)
+		}
+	}
+
+	for i, namespace := range r.Spec.Namespaces {
+		if err := validatePattern(string(namespace)); err != nil {
+			return fmt.Errorf("spec.namespaces[%d]: %s", i, err)
+		}
+	}
+
+	for i, item := range r.Spec.Items {
+
+		if err := validatePattern(item.Object.Kind); err != nil {
+			return fmt.Errorf("spec.items[%d].object.kind: %s", i, err)
+		}
+		if err := validatePattern(item.Object.Metadata.Name); err != nil {
+			return fmt.Errorf("spec.items[%d].object.metadata.name: %s", i, err)
+		}
+		if err := validatePattern(string(item.Object.Metadata.Namespace)); err != nil {
+			return fmt.Errorf("spec.items[%d].object.metadata.namespace: %s", i, err)
+		}
+
+		if item.Constraints != nil && item.Constraints.Schema != nil && item.Constraints.Schema.Raw != nil {
+			if _, err := newSchemaValidator(item.Constraints.Schema.Raw); err != nil {
+				return fmt.Errorf("spec.items[%d].constraints.schema: %s", i, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validatePattern ensures a value of the binding compiles if it is a pattern
+func validatePattern(value string) error {
+
+	if !isPattern(value) {
+		return nil
+	}
+
+	_, err := compilePattern(value)
+	return err
+}
+
+// matchesNamespace checks whether the binding applies to the namespace by its name or its labels
+func (r *ManagedResourceBinding) matchesNamespace(namespace utils.Namespace, namespaceLabels labels.Set) bool {
+
+	for _, bindingNamespace := range r.Spec.Namespaces {
+		if matchesPattern(string(bindingNamespace), string(namespace)) {
+			return true
+		}
+	}
+
+	// Invalid selectors are rejected when the binding is created, so they never match
+	if r.Spec.NamespaceSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(r.Spec.NamespaceSelector)
+		if err != nil {
+			return false
+		}
+		return selector.Matches(namespaceLabels)
+	}
+
+	return false
+}
+
+// matchesRequester checks whether the binding applies to the user, which is unknown if nil
+func (r *ManagedResourceBinding) matchesRequester(requester *authenticationv1.UserInfo) bool {
+
+	if len(r.Spec.Subjects) == 0 {
+		return true
+	} else if requester == nil {
+		return false
+	}
+
+	for _, subject := range r.Spec.Subjects {
+		switch subject.Kind {
+		case rbacv1.UserKind:
+			if subject.Name == requester.Username {
+				return true
+			}
+		case rbacv1.GroupKind:
+			for _, group := range requester.Groups {
+				if subject.Name == group {
+					return true
+				}
+			}
+		case rbacv1.ServiceAccountKind:
+			if serviceAccountPrefix+subject.Namespace+":"+subject.Name == requester.Username {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2020 Vladislav Poberezhny.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/restmapper"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"operator/pkg/utils"
+)
+
+// regexPrefix marks a value of a binding as a regular expression
+const regexPrefix = "~"
+
+// coreGroup is the name binding items use for the core API group, which has no name of its own
+const coreGroup = "core"
+
+// mapperResetInterval is the least time between discovering the API resources again for a resource name which is not known
+const mapperResetInterval = time.Minute
+
+var (
+	restMapper          meta.RESTMapper
+	discoveryRESTMapper *restmapper.DeferredDiscoveryRESTMapper
+	restMapperReset     time.Time
+	restMapperMux       sync.Mutex
+)
+
+// getRESTMapper returns a mapper which resolves resource names and short names to kinds
+func getRESTMapper() (meta.RESTMapper, error) {
+	restMapperMux.Lock()
+	defer restMapperMux.Unlock()
+
+	if restMapper == nil {
+
+		discoveryClient, err := discovery.NewDiscoveryClientForConfig(ctrl.GetConfigOrDie())
+		if err != nil {
+			return nil, errors.New("an error occurred while creating discovery client: " + err.Error())
+		}
+
+		cachedDiscoveryClient := memory.NewMemCacheClient(discoveryClient)
+		discoveryRESTMapper = restmapper.NewDeferredDiscoveryRESTMapper(cachedDiscoveryClient)
+		restMapper = restmapper.NewShortcutExpander(discoveryRESTMapper, cachedDiscoveryClient)
+		restMapperReset = time.Now()
+	}
+
+	return restMapper, nil
+}
+
+// resetRESTMapper discovers the API resources again unless they were discovered recently
+func resetRESTMapper() bool {
+	restMapperMux.Lock()
+	defer restMapperMux.Unlock()
+
+	if discoveryRESTMapper == nil || time.Since(restMapperReset) < mapperResetInterval {
+		return false
+	}
+
+	discoveryRESTMapper.Reset()
+	restMapperReset = time.Now()
+
+	return true
+}
+
+// matchesObject checks whether the object of a binding item refers to the target object
+func matchesObject(item utils.ManagedResourceStruct, target *utils.ManagedResourceStruct) (bool, error) {
+
+	if !matchesPattern(item.Metadata.Name, target.Metadata.Name) || !matchesPattern(string(item.Metadata.Namespace), string(target.Metadata.Namespace)) {
+		return false, nil
+	}
+
+	targetGroupVersion, err := schema.ParseGroupVersion(target.APIVersion)
+	if err != nil {
+		return false, errors.New("an error occurred while reading API version of " + target.Kind + ": " + err.Error())
+	}
+
+	// Match the group and version of the item, if any
+	itemGroupVersion := schema.GroupVersion{Group: "*", Version: "*"}
+	if item.APIVersion != "" {
+		itemGroupVersion = parseGroupVersion(item.APIVersion)
+	}
+	if item.Group != "" {
+		group := item.Group
+		if group == coreGroup {
+			group = ""
+		}
+		if !matchesValue(group, targetGroupVersion.Group) {
+			return false, nil
+		}
+		if itemGroupVersion.Group == "*" {
+			itemGroupVersion.Group = group
+		}
+	}
+	if !matchesValue(itemGroupVersion.Group, targetGroupVersion.Group) || !matchesValue(itemGroupVersion.Version, targetGroupVersion.Version) {
+		return false, nil
+	}
+
+	return matchesKind(item.Kind, itemGroupVersion, targetGroupVersion.WithKind(target.Kind))
+}
+
+// matchesKind checks whether the kind of a binding item, which may be a resource name or a pattern of kinds, refers to the target kind
+func matchesKind(kind string, groupVersion schema.GroupVersion, target schema.GroupVersionKind) (bool, error) {
+
+	if isPattern(kind) {
+		return matchesPattern(kind, target.Kind), nil
+	} else if kind == target.Kind {
+		return true, nil
+	}
+
+	mapper, err := getRESTMapper()
+	if err != nil {
+		return false, err
+	}
+
+	// Resolve the kind as a resource within the group and version of the item, where empty fields match any
+	resource := schema.GroupVersionResource{Resource: strings.ToLower(kind)}
+	if groupVersion.Group != "*" {
+		resource.Group = groupVersion.Group
+	}
+	if groupVersion.Version != "*" {
+		resource.Version = groupVersion.Version
+	}
+
+	kinds, err := mapper.KindsFor(resource)
+	if meta.IsNoMatchError(err) && resetRESTMapper() {
+		kinds, err = mapper.KindsFor(resource)
+	}
+	if meta.IsNoMatchError(err) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.New("an error occurred while resolving kind " + kind + ": " + err.Error())
+	}
+
+	for _, resolvedKind := range kinds {
+		if resolvedKind.GroupKind() == target.GroupKind() {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Compiled patterns of binding values
+var (
+	patterns    = make(map[string]*regexp.Regexp)
+	patternsMux sync.Mutex
+)
+
+// isPattern checks whether a value of a binding is a glob or a regular expression rather than a literal value
+func isPattern(value string) bool {
+	return strings.HasPrefix(value, regexPrefix) || strings.Contains(value, "*")
+}
+
+// compilePattern compiles a value of a binding, which is a literal value, a glob where * matches any characters or a regular expression prefixed with ~,
+// to a regular expression matching the whole target value
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	patternsMux.Lock()
+	defer patternsMux.Unlock()
+
+	if compiled, ok := patterns[pattern]; ok {
+		return compiled, nil
+	}
+
+	var expression string
+	if strings.HasPrefix(pattern, regexPrefix) {
+		expression = strings.TrimPrefix(pattern, regexPrefix)
+	} else {
+		globParts := strings.Split(pattern, "*")
+		for i, globPart := range globParts {
+			globParts[i] = regexp.QuoteMeta(globPart)
+		}
+		expression = strings.Join(globParts, ".*")
+	}
+
+	compiled, err := regexp.Compile("^(?:" + expression + ")$")
+	if err != nil {
+		return nil, errors.New("an error occurred while compiling pattern " + pattern + ": " + err.Error())
+	}
+	patterns[pattern] = compiled
+
+	return compiled, nil
+}
+
+// matchesPattern checks whether a value of a binding, which may be a pattern, matches the target value
+func matchesPattern(pattern string, target string) bool {
+
+	if !isPattern(pattern) {
+		return pattern == target
+	}
+
+	// Invalid patterns are rejected when the binding is created, so they never match
+	compiled, err := compilePattern(pattern)
+	if err != nil {
+		return false
+	}
+
+	return compiled.MatchString(target)
+}
+
+// matchesValue checks whether a value of a binding item matches the target value
+func matchesValue(value string, target string) bool {
+	return value == "*" || value == target
+}
+
+// parseGroupVersion reads the API version of a binding item, where the group of a version without one is the core group
+func parseGroupVersion(apiVersion string) schema.GroupVersion {
+
+	if apiVersion == "*" {
+		return schema.GroupVersion{Group: "*", Version: "*"}
+	} else if index := strings.LastIndex(apiVersion, "/"); index != -1 {
+		return schema.GroupVersion{Group: apiVersion[:index], Version: apiVersion[index+1:]}
+	}
+
+	return schema.GroupVersion{Version: apiVersion}
+}
@@ -0,0 +1,172 @@
+package v1beta1
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"operator/pkg/utils"
+)
+
+func TestCompilePattern(t *testing.T) {
+
+	tests := []struct {
+		pattern    string
+		expression string
+		wantErr    bool
+	}{
+		{pattern: "team-*", expression: "^(?:team-.*)$"},
+		{pattern: "*.example.com", expression: "^(?:.*\\.example\\.com)$"},
+		{pattern: "a+b*", expression: "^(?:a\\+b.*)$"},
+		{pattern: "~team-[a-z]+", expression: "^(?:team-[a-z]+)$"},
+		{pattern: "~a|b", expression: "^(?:a|b)$"},
+		{pattern: "~team-[a-z", wantErr: true},
+		{pattern: "~(", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern, func(t *testing.T) {
+			compiled, err := compilePattern(test.pattern)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("compilePattern() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if err == nil && compiled.String() != test.expression {
+				t.Errorf("compilePattern() = %s, want %s", compiled, test.expression)
+			}
+		})
+	}
+}
+
+func TestMatchesPattern(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		pattern string
+		target  string
+		matches bool
+	}{
+		{name: "literal", pattern: "payments", target: "payments", matches: true},
+		{name: "literal mismatch", pattern: "payments", target: "payments-dev", matches: false},
+		{name: "literal is not a regular expression", pattern: "team.a", target: "teamxa", matches: false},
+		{name: "empty literal", pattern: "", target: "", matches: true},
+		{name: "star", pattern: "*", target: "anything", matches: true},
+		{name: "star matches empty", pattern: "*", target: "", matches: true},
+		{name: "glob prefix", pattern: "team-*", target: "team-a", matches: true},
+		{name: "glob prefix mismatch", pattern: "team-*", target: "xteam-a", matches: false},
+		{name: "glob suffix", pattern: "*-dev", target: "payments-dev", matches: true},
+		{name: "glob suffix mismatch", pattern: "*-dev", target: "payments-dev-2", matches: false},
+		{name: "glob middle", pattern: "team-*-dev", target: "team-a-b-dev", matches: true},
+		{name: "glob quotes metacharacters", pattern: "a.b*", target: "axb", matches: false},
+		{name: "glob crosses dots", pattern: "*.example.com", target: "a.b.example.com", matches: true},
+		{name: "regular expression", pattern: "~team-[a-z]+", target: "team-abc", matches: true},
+		{name: "regular expression is anchored at the start", pattern: "~team-[a-z]+", target: "xteam-abc", matches: false},
+		{name: "regular expression is anchored at the end", pattern: "~team-[a-z]+", target: "team-abc-1", matches: false},
+		{name: "alternation is anchored", pattern: "~a|b", target: "ab", matches: false},
+		{name: "alternation", pattern: "~a|b", target: "b", matches: true},
+		{name: "invalid regular expression never matches", pattern: "~(", target: "(", matches: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if matches := matchesPattern(test.pattern, test.target); matches != test.matches {
+				t.Errorf("matchesPattern(%q, %q) = %v, want %v", test.pattern, test.target, matches, test.matches)
+			}
+		})
+	}
+}
+
+func TestIsPattern(t *testing.T) {
+
+	tests := []struct {
+		value     string
+		isPattern bool
+	}{
+		{value: "payments", isPattern: false},
+		{value: "", isPattern: false},
+		{value: "team-*", isPattern: true},
+		{value: "~team", isPattern: true},
+		{value: "team~", isPattern: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			if isPattern := isPattern(test.value); isPattern != test.isPattern {
+				t.Errorf("isPattern(%q) = %v, want %v", test.value, isPattern, test.isPattern)
+			}
+		})
+	}
+}
+
+func TestParseGroupVersion(t *testing.T) {
+
+	tests := []struct {
+		apiVersion   string
+		groupVersion schema.GroupVersion
+	}{
+		{apiVersion: "v1", groupVersion: schema.GroupVersion{Version: "v1"}},
+		{apiVersion: "apps/v1", groupVersion: schema.GroupVersion{Group: "apps", Version: "v1"}},
+		{apiVersion: "*", groupVersion: schema.GroupVersion{Group: "*", Version: "*"}},
+		{apiVersion: "*/v1", groupVersion: schema.GroupVersion{Group: "*", Version: "v1"}},
+		{apiVersion: "apps/*", groupVersion: schema.GroupVersion{Group: "apps", Version: "*"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.apiVersion, func(t *testing.T) {
+			if groupVersion := parseGroupVersion(test.apiVersion); groupVersion != test.groupVersion {
+				t.Errorf("parseGroupVersion(%q) = %v, want %v", test.apiVersion, groupVersion, test.groupVersion)
+			}
+		})
+	}
+}
+
+// TestMatchesObject only uses kinds which are matched without discovering the API resources
+func TestMatchesObject(t *testing.T) {
+
+	object := func(apiVersion string, group string, kind string, namespace string, name string) utils.ManagedResourceStruct {
+		return utils.ManagedResourceStruct{
+			APIVersion: apiVersion,
+			Group:      group,
+			Kind:       kind,
+			Metadata:   utils.MetadataStruct{Name: name, Namespace: utils.Namespace(namespace)},
+		}
+	}
+	deployment := object("apps/v1", "", "Deployment", "team-a", "app")
+	configMap := object("v1", "", "ConfigMap", "team-a", "settings")
+
+	tests := []struct {
+		name    string
+		item    utils.ManagedResourceStruct
+		target  utils.ManagedResourceStruct
+		matches bool
+	}{
+		{name: "exact", item: object("apps/v1", "", "Deployment", "team-a", "app"), target: deployment, matches: true},
+		{name: "any API version", item: object("", "", "Deployment", "team-a", "app"), target: deployment, matches: true},
+		{name: "other version", item: object("apps/v2", "", "Deployment", "team-a", "app"), target: deployment, matches: false},
+		{name: "any version of the group", item: object("", "apps", "Deployment", "team-a", "app"), target: deployment, matches: true},
+		{name: "other group", item: object("", "batch", "Deployment", "team-a", "app"), target: deployment, matches: false},
+		{name: "core group", item: object("", "core", "ConfigMap", "team-a", "settings"), target: configMap, matches: true},
+		{name: "core group does not match a named group", item: object("", "core", "Deployment", "team-a", "app"), target: deployment, matches: false},
+		{name: "version without group is the core group", item: object("v1", "", "Deployment", "team-a", "app"), target: deployment, matches: false},
+		{name: "wildcard group", item: object("*/v1", "", "ConfigMap", "team-a", "settings"), target: configMap, matches: true},
+		{name: "kind glob", item: object("*", "", "Config*", "team-a", "settings"), target: configMap, matches: true},
+		{name: "kind regular expression", item: object("*", "", "~Deployment|StatefulSet", "team-a", "app"), target: deployment, matches: true},
+		{name: "kind pattern mismatch", item: object("*", "", "~StatefulSet", "team-a", "app"), target: deployment, matches: false},
+		{name: "name glob", item: object("*", "", "*", "team-a", "app*"), target: deployment, matches: true},
+		{name: "name mismatch", item: object("*", "", "*", "team-a", "other"), target: deployment, matches: false},
+		{name: "namespace glob", item: object("*", "", "*", "team-*", "app"), target: deployment, matches: true},
+		{name: "namespace mismatch", item: object("*", "", "*", "team-b", "app"), target: deployment, matches: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			target := test.target
+			matches, err := matchesObject(test.item, &target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if matches != test.matches {
+				t.Errorf("matchesObject() = %v, want %v", matches, test.matches)
+			}
+		})
+	}
+}
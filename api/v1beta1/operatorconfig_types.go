@@ -0,0 +1,147 @@
+/*
+Copyright 2020 Vladislav Poberezhny.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"errors"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"operator/pkg/utils"
+)
+
+// OperatorConfigName is the name of the only operator config which is used by the operator
+const OperatorConfigName = "cluster"
+
+// Reconciliation defaults used if no operator config is present
+const (
+	DefaultReconcileInterval       = time.Minute
+	DefaultMaxConcurrentReconciles = 1
+)
+
+// MaxConcurrentReconcilesLimit is the highest number of managed resources which may be reconciled at the same time
+const MaxConcurrentReconcilesLimit = 32
+
+// OperatorConfigSpec defines the desired configuration of the operator
+type OperatorConfigSpec struct {
+
+	// HTTP defines how URL sources are fetched
+	// +optional
+	HTTP utils.HTTPConfig `json:"http,omitempty"`
+
+	// ReconcileInterval is the time between reconciliations of each managed resource, defaults to 1m
+	// +optional
+	ReconcileInterval metav1.Duration `json:"reconcileInterval,omitempty"`
+
+	// MaxConcurrentReconciles is the number of managed resources which may be reconciled at the same time, defaults to 1
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=32
+	// +optional
+	MaxConcurrentReconciles int `json:"maxConcurrentReconciles,omitempty"`
+
+	// Features toggles optional features of the operator
+	// +optional
+	Features utils.FeaturesConfig `json:"features,omitempty"`
+
+	// Signatures defines the signatures sources are verified with
+	// +optional
+	Signatures utils.SignaturesConfig `json:"signatures,omitempty"`
+}
+
+// WithDefaults returns the operator configuration with unset fields defaulted
+func (s OperatorConfigSpec) WithDefaults() OperatorConfigSpec {
+
+	s.HTTP = s.HTTP.WithDefaults()
+	s.Features = s.Features.WithDefaults()
+	if s.ReconcileInterval.Duration == 0 {
+		s.ReconcileInterval.Duration = DefaultReconcileInterval
+	}
+	if s.MaxConcurrentReconciles == 0 {
+		s.MaxConcurrentReconciles = DefaultMaxConcurrentReconciles
+	}
+
+	return s
+}
+
+// Validate ensures the operator configuration can be used
+func (s OperatorConfigSpec) Validate() error {
+
+	if err := s.HTTP.Validate(); err != nil {
+		return err
+	}
+
+	if err := s.Signatures.Validate(); err != nil {
+		return err
+	}
+
+	if s.ReconcileInterval.Duration < 0 {
+		return errors.New("reconcileInterval must not be negative")
+	}
+
+	if s.MaxConcurrentReconciles < 0 || s.MaxConcurrentReconciles > MaxConcurrentReconcilesLimit {
+		return errors.New("maxConcurrentReconciles must be between 1 and 32")
+	}
+
+	return nil
+}
+
+// OperatorConfigStatus defines the observed state of OperatorConfig
+type OperatorConfigStatus struct {
+
+	// Conditions are the latest observations of the configuration state
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []Condition `json:"conditions,omitempty"`
+
+	// ObservedGeneration is the most recent generation processed by the operator
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Effective is the configuration the operator currently runs with, including defaults
+	// +optional
+	Effective *OperatorConfigSpec `json:"effective,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=oc,scope=Cluster
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+
+// OperatorConfig is the Schema for the operatorconfigs API
+type OperatorConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   OperatorConfigSpec   `json:"spec,omitempty"`
+	Status OperatorConfigStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// OperatorConfigList contains a list of OperatorConfig
+type OperatorConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []OperatorConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&OperatorConfig{}, &OperatorConfigList{})
+}
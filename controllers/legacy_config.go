@@ -0,0 +1,130 @@
+/*
+Copyright 2020 Vladislav Poberezhny.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	paasv1beta1 "operator/api/v1beta1"
+)
+
+// Deprecated environment variables the operator was configured with before the operator config
+const (
+	envHTTPInsecure             = "HTTP_INSECURE"
+	envHTTPTimeout              = "HTTP_TIMEOUT"
+	envHTTPCABundlePath         = "HTTP_CA_BUNDLE_PATH"
+	envReconciliationIntervalMS = "RECONCILIATION_INTERVAL_MS"
+	envSignaturePublicKeyPath   = "SIGNATURE_PUBLIC_KEY_PATH"
+)
+
+// LegacyConfigFromEnvironment reads the deprecated environment variables as an operator config, logging a deprecation warning for each one which is set and ignoring invalid ones,
+// except for an invalid signature public key which is returned as an error since ignoring it would stop requiring signatures
+func LegacyConfigFromEnvironment(log logr.Logger) (paasv1beta1.OperatorConfigSpec, error) {
+
+	spec := paasv1beta1.OperatorConfigSpec{}
+
+	// Read a deprecated variable along with the operator config field replacing it
+	lookup := func(name string, field string, parse func(value string) error) {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+
+		log.Info("the "+name+" environment variable is deprecated and will be removed, set "+field+" of the "+paasv1beta1.OperatorConfigName+" operator config instead", "variable", name)
+		if err := parse(value); err != nil {
+			log.Error(err, "ignoring invalid environment variable", "variable", name)
+		}
+	}
+
+	lookup(envHTTPInsecure, "http.insecure", func(value string) error {
+		insecure, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		spec.HTTP.Insecure = insecure
+		return nil
+	})
+	lookup(envHTTPTimeout, "http.timeout", func(value string) error {
+		seconds, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return err
+		}
+		spec.HTTP.Timeout.Duration = time.Duration(seconds) * time.Second
+		return nil
+	})
+	lookup(envHTTPCABundlePath, "http.caBundle", func(value string) error {
+		caBundle, err := ioutil.ReadFile(value)
+		if err != nil {
+			return err
+		} else if ok := x509.NewCertPool().AppendCertsFromPEM(caBundle); !ok {
+			return errors.New(value + " contains no PEM encoded certificates")
+		}
+		spec.HTTP.CABundle = string(caBundle)
+		return nil
+	})
+	lookup(envReconciliationIntervalMS, "reconcileInterval", func(value string) error {
+		intervalMS, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return err
+		}
+		spec.ReconcileInterval.Duration = time.Duration(intervalMS) * time.Millisecond
+		return nil
+	})
+
+	// Signatures are required as long as the variable is set
+	if publicKeyPath, ok := os.LookupEnv(envSignaturePublicKeyPath); ok {
+		log.Info("the "+envSignaturePublicKeyPath+" environment variable is deprecated and will be removed, set signatures.publicKey of the "+paasv1beta1.OperatorConfigName+" operator config instead", "variable", envSignaturePublicKeyPath)
+
+		publicKey, err := ioutil.ReadFile(publicKeyPath)
+		if err != nil {
+			return spec, errors.New("an error occurred while reading " + envSignaturePublicKeyPath + ": " + err.Error())
+		}
+		spec.Signatures.PublicKey = string(publicKey)
+		if err := spec.Signatures.Validate(); err != nil {
+			return spec, errors.New("an error occurred while reading " + envSignaturePublicKeyPath + ": " + err.Error())
+		}
+	}
+
+	return spec, nil
+}
+
+// withLegacyConfig returns the operator config with the fields it leaves unset taken from the legacy config
+func withLegacyConfig(spec paasv1beta1.OperatorConfigSpec, legacy paasv1beta1.OperatorConfigSpec) paasv1beta1.OperatorConfigSpec {
+
+	spec.HTTP.Insecure = spec.HTTP.Insecure || legacy.HTTP.Insecure
+	if spec.HTTP.Timeout.Duration == 0 {
+		spec.HTTP.Timeout = legacy.HTTP.Timeout
+	}
+	if spec.HTTP.CABundle == "" {
+		spec.HTTP.CABundle = legacy.HTTP.CABundle
+	}
+	if spec.ReconcileInterval.Duration == 0 {
+		spec.ReconcileInterval = legacy.ReconcileInterval
+	}
+	if spec.Signatures.PublicKey == "" {
+		spec.Signatures.PublicKey = legacy.Signatures.PublicKey
+	}
+
+	return spec
+}
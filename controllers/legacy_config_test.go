@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	paasv1beta1 "operator/api/v1beta1"
+)
+
+// setEnv sets the environment variables until the returned function is called
+func setEnv(t *testing.T, variables map[string]string) func() {
+
+	for name, value := range variables {
+		if err := os.Setenv(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return func() {
+		for name := range variables {
+			os.Unsetenv(name)
+		}
+	}
+}
+
+func TestLegacyConfigFromEnvironment(t *testing.T) {
+
+	caBundle, err := ioutil.TempFile("", "ca-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(caBundle.Name())
+	caBundle.Close()
+
+	// Invalid variables are ignored, as is a CA bundle without certificates
+	defer setEnv(t, map[string]string{
+		envHTTPInsecure:             "true",
+		envHTTPTimeout:              "30",
+		envHTTPCABundlePath:         caBundle.Name(),
+		envReconciliationIntervalMS: "one minute",
+	})()
+
+	legacy, err := LegacyConfigFromEnvironment(ctrl.Log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !legacy.HTTP.Insecure || legacy.HTTP.Timeout.Duration != 30*time.Second || legacy.HTTP.CABundle != "" || legacy.ReconcileInterval.Duration != 0 {
+		t.Fatalf("unexpected legacy config %+v", legacy)
+	}
+
+	// The operator config takes precedence over the legacy config
+	spec := paasv1beta1.OperatorConfigSpec{}
+	spec.HTTP.Timeout.Duration = 5 * time.Second
+	effective := withLegacyConfig(spec, legacy).WithDefaults()
+	if !effective.HTTP.Insecure || effective.HTTP.Timeout.Duration != 5*time.Second || effective.ReconcileInterval.Duration != paasv1beta1.DefaultReconcileInterval {
+		t.Errorf("unexpected effective config %+v", effective)
+	}
+
+	if effective := withLegacyConfig(paasv1beta1.OperatorConfigSpec{}, legacy).WithDefaults(); effective.HTTP.Timeout.Duration != 30*time.Second {
+		t.Errorf("timeout = %s, want the legacy timeout", effective.HTTP.Timeout.Duration)
+	}
+}
+
+func TestLegacySignaturePublicKey(t *testing.T) {
+
+	// A signature key which cannot be read is not ignored, as signatures would no longer be required
+	defer setEnv(t, map[string]string{envSignaturePublicKeyPath: "/nonexistent/key.pem"})()
+
+	if _, err := LegacyConfigFromEnvironment(ctrl.Log); err == nil {
+		t.Error("expected an error for a missing signature public key")
+	}
+}
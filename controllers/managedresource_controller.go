@@ -19,35 +19,80 @@ package controllers
 import (
 	"context"
 	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/prometheus/common/log"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	paasv1beta1 "operator/api/v1beta1"
 
 	"operator/pkg/utils"
 )
 
+// informerSyncTimeout is the time to wait for a managed object informer to sync
+const informerSyncTimeout = 10 * time.Second
+
+// Index fields of managed resources by the objects their sources reference
+const (
+	configMapRefIndex = ".spec.source.configMapRef.name"
+	secretRefIndex    = ".spec.source.secretRef.name"
+)
+
+// managedObjectFinalizer ensures managed objects are deleted along with their managed resource
+const managedObjectFinalizer = "managedobject.finalizers.managedresources.paas.il"
+
 // ManagedResourceReconciler reconciles a ManagedResource object
 type ManagedResourceReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+
+	controller      controller.Controller
+	cache           cache.Cache
+	watchedKinds    map[schema.GroupVersionKind]bool
+	watchedKindsMux sync.Mutex
+
+	limiter           *reconcileLimiter
+	reconcileInterval time.Duration
+	settingsMux       sync.RWMutex
 }
 
 // +kubebuilder:rbac:groups=paas.il,resources=managedresources,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=paas.il,resources=managedresources/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=configmaps;secrets,verbs=get;list;watch
 
 // Reconcile reconciles a received resource
 func (r *ManagedResourceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
 	_ = r.Log.WithValues("managedresource", req.NamespacedName)
 
+	// Limit the number of managed resources which are reconciled at the same time
+	r.limiter.acquire()
+	defer r.limiter.release()
+
 	// Get managed resource k8s object
 	managedResource := &paasv1beta1.ManagedResource{}
 	if err := r.Get(ctx, req.NamespacedName, managedResource); err != nil {
@@ -57,86 +102,706 @@ func (r *ManagedResourceReconciler) Reconcile(req ctrl.Request) (ctrl.Result, er
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// Delete the managed objects recorded in the status if their CR is being deleted, so a source which can no longer be read does not block the deletion
+	if !managedResource.DeletionTimestamp.IsZero() {
+		if controllerutil.ContainsFinalizer(managedResource, managedObjectFinalizer) {
+			return ctrl.Result{}, r.finalize(ctx, managedResource)
+		}
+		return ctrl.Result{}, nil
+	}
+
 	// Process object source
-	_, _, managedObject, managedObjectKey, err := utils.ProcessSource(managedResource.Spec.Source)
+	managedObjects, sourceStatus, err := managedResource.ManagedObjects(r.Client)
 	if err != nil {
 		log.Error(err)
-		return ctrl.Result{}, err
+		r.setFailed(managedResource, paasv1beta1.ConditionSynced, sourceErrorReason(err), err)
+		return ctrl.Result{}, r.updateStatus(ctx, managedResource, err)
 	}
 
-	managedObjectFinalizer := "managedobject.finalizers.managedresources.paas.il"
-
-	// Delete the managed object if its CR is being deleted
-	if !managedResource.DeletionTimestamp.IsZero() && controllerutil.ContainsFinalizer(managedResource, managedObjectFinalizer) {
-		controllerutil.RemoveFinalizer(managedResource, managedObjectFinalizer)
-
-		// Delete object if it exists
-		if err := r.Client.Delete(ctx, managedObject); err != nil && !apierrors.IsNotFound(err) {
+	// Ensure the bindings still permit managing the objects, which only have to be creatable until they exist, enforcing the overlays of the binding items
+	overlays := make(map[string]string)
+	for i, managedObject := range managedObjects {
+		var grant paasv1beta1.PermissionGrant
+		exists, err := paasv1beta1.ObjectExists(r.Client, managedObject)
+		if err == nil {
+			grant, err = paasv1beta1.CheckApplyPermissions(managedObject, utils.Namespace(managedResource.Namespace), managedResource.Requester(), exists)
+		}
+		if err != nil {
+			err = fmt.Errorf("%s: %w", managedObject, err)
 			log.Error(err)
-			return ctrl.Result{}, err
+			r.setFailed(managedResource, paasv1beta1.ConditionAuthorized, paasv1beta1.ReasonPermissionDenied, err)
+
+			// Wait for the next sync if permission was explicitly denied
+			if errors.Is(err, paasv1beta1.ErrPermissionDenied) {
+				return r.requeueResult(managedResource), r.updateStatus(ctx, managedResource, nil)
+			}
+			return ctrl.Result{}, r.updateStatus(ctx, managedResource, err)
 		}
 
-		// Update finalizers field for CR
-		if err := r.Client.Update(ctx, managedResource); err != nil {
+		if managedObjects[i], err = grant.ApplyOverlay(managedObject); err != nil {
+			err = fmt.Errorf("%s: %w", managedObject, err)
 			log.Error(err)
-			return ctrl.Result{}, err
+			r.setFailed(managedResource, paasv1beta1.ConditionSynced, paasv1beta1.ReasonInvalidOverlay, err)
+			return ctrl.Result{}, r.updateStatus(ctx, managedResource, err)
 		}
+		if grant.Overlay.Raw != nil {
+			overlays[managedObject.Identity()] = grant.String()
+		}
+	}
+	r.setCondition(managedResource, paasv1beta1.ConditionAuthorized, paasv1beta1.ConditionTrue, paasv1beta1.ReasonPermitted, "")
 
-		return ctrl.Result{}, nil
+	// Leave the objects as they are if a refreshed source did not change since they were last applied
+	if r.isUpToDate(ctx, managedResource, managedObjects, sourceStatus) {
+		managedResource.Status.Source = &sourceStatus
+		return r.requeueResult(managedResource), r.updateStatus(ctx, managedResource, nil)
 	}
 
 	// Add finalizer for managed resource
 	controllerutil.AddFinalizer(managedResource, managedObjectFinalizer)
 
-	// Annotate managed object with its owner namespace and name
-	managedResourceAnnotations := managedObject.(controllerutil.Object).GetAnnotations()
+	// Apply objects in order, keeping track of each result
+	var applyErr error
+	var applyReason string
+	var conflicts []string
+	objectStatuses := make([]paasv1beta1.ManagedObjectStatus, 0, len(managedObjects))
+	for _, managedObject := range managedObjects {
+
+		objectStatus, objectConflicts, err := r.applyObject(ctx, managedResource, managedObject)
+		objectStatus.Overlay = overlays[managedObject.Identity()]
+		objectStatuses = append(objectStatuses, objectStatus)
+		conflicts = append(conflicts, objectConflicts...)
+
+		if err != nil && applyErr == nil {
+			applyErr = fmt.Errorf("%s: %w", managedObject, err)
+			applyReason = objectStatus.Reason
+		}
+	}
+
+	// Prune objects which were removed from the source
+	prunedObjectStatuses, err := r.pruneObjects(ctx, managedResource, managedObjects)
+	objectStatuses = append(objectStatuses, prunedObjectStatuses...)
+	if err != nil && applyErr == nil {
+		applyErr = err
+		applyReason = paasv1beta1.ReasonPruneFailed
+	}
+
+	// Keep the computed status since updating the CR overwrites it with the stored one
+	status := managedResource.Status.DeepCopy()
+
+	// Update managed resource with finalizer field
+	if err := r.Update(context.Background(), managedResource); err != nil {
+		log.Error(err)
+		return ctrl.Result{}, err
+	}
+
+	// Record the live managed objects
+	managedResource.Status = *status
+	managedResource.Status.Objects = objectStatuses
+	managedResource.Status.Conflicts = conflicts
+	if applyErr != nil {
+		log.Error(applyErr)
+		r.setFailed(managedResource, paasv1beta1.ConditionSynced, applyReason, applyErr)
+		return ctrl.Result{}, r.updateStatus(ctx, managedResource, applyErr)
+	}
+	r.setSynced(managedResource)
+
+	// Record the fields a persistent overwrite enforced
+	if managedResource.Status.EnforcedFields, err = managedResource.EnforcedFields(); err != nil {
+		log.Error(err)
+	}
+
+	// Record the source contents which were applied
+	managedResource.Status.Source = nil
+	if sourceStatus != (utils.SourceStatus{}) {
+		managedResource.Status.Source = &sourceStatus
+	}
+
+	return r.requeueResult(managedResource), r.updateStatus(ctx, managedResource, nil)
+}
+
+// isUpToDate checks whether a refreshed source is unchanged since its objects were last applied and none of them drifted
+func (r *ManagedResourceReconciler) isUpToDate(ctx context.Context, managedResource *paasv1beta1.ManagedResource, managedObjects []utils.ManagedObject, sourceStatus utils.SourceStatus) bool {
+
+	// Only refreshed sources are compared by their digest
+	lastSourceStatus := managedResource.Status.Source
+	if managedResource.Spec.Source.Refresh == nil || lastSourceStatus == nil || lastSourceStatus.Digest != sourceStatus.Digest {
+		return false
+	}
+
+	// The current generation of the managed resource must have been applied successfully
+	readyCondition := paasv1beta1.FindCondition(managedResource.Status.Conditions, paasv1beta1.ConditionReady)
+	if managedResource.Status.ObservedGeneration != managedResource.Generation || readyCondition == nil || readyCondition.Status != paasv1beta1.ConditionTrue {
+		return false
+	}
+
+	// Ensure none of the objects were changed out of band
+	desiredObjects := make(map[string]runtime.Object, len(managedObjects))
+	for _, managedObject := range managedObjects {
+		desiredObjects[managedObject.Identity()] = managedObject.Object
+	}
+	for i := range managedResource.Status.Objects {
+		objectStatus := &managedResource.Status.Objects[i]
+
+		object := &unstructured.Unstructured{}
+		object.SetAPIVersion(objectStatus.APIVersion)
+		object.SetKind(objectStatus.Kind)
+		var clusterObject runtime.Object = object
+		if err := r.Client.Get(ctx, types.NamespacedName{Namespace: objectStatus.Namespace, Name: objectStatus.Name}, object); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return false
+			}
+			clusterObject = nil
+		}
+
+		if objectDrift(objectStatus, desiredObjects[objectStatus.Identity()], clusterObject) != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// requeueResult requeues the managed resource after the reconcile interval or when its source should be fetched again, whichever comes first
+func (r *ManagedResourceReconciler) requeueResult(managedResource *paasv1beta1.ManagedResource) ctrl.Result {
+	r.settingsMux.RLock()
+	interval := r.reconcileInterval
+	r.settingsMux.RUnlock()
+
+	if refresh := managedResource.Spec.Source.Refresh; refresh != nil && refresh.RefreshInterval() < interval {
+		interval = refresh.RefreshInterval()
+	}
+
+	return ctrl.Result{RequeueAfter: interval}
+}
+
+// configure changes the reconciliation settings of managed resources
+func (r *ManagedResourceReconciler) configure(reconcileInterval time.Duration, maxConcurrentReconciles int) {
+	r.settingsMux.Lock()
+	defer r.settingsMux.Unlock()
+
+	r.reconcileInterval = reconcileInterval
+	r.limiter.setLimit(maxConcurrentReconciles)
+}
+
+// applyObject creates or updates a single managed object and returns its status and field conflicts
+func (r *ManagedResourceReconciler) applyObject(ctx context.Context, managedResource *paasv1beta1.ManagedResource, managedObject utils.ManagedObject) (paasv1beta1.ManagedObjectStatus, []string, error) {
+
+	// Start from the previous status of the object so it is still tracked if applying fails
+	objectStatus := newObjectStatus(managedObject.Object)
+	lastObjectStatus := findObjectStatus(managedResource.Status.Objects, objectStatus.Identity())
+	if lastObjectStatus != nil {
+		objectStatus = *lastObjectStatus
+	}
+
+	// Record a failure of the object
+	fail := func(reason string, err error) (paasv1beta1.ManagedObjectStatus, []string, error) {
+		objectStatus.Reason = reason
+		objectStatus.Message = paasv1beta1.TruncateMessage(err.Error())
+		return objectStatus, nil, err
+	}
+
+	// Annotate managed object with its owner namespace and name and the user who requested it
+	object := managedObject.Object.DeepCopyObject()
+	managedResourceAnnotations := object.(controllerutil.Object).GetAnnotations()
 	if managedResourceAnnotations == nil {
 		managedResourceAnnotations = make(map[string]string)
 	}
-	managedResourceAnnotations[utils.ManagedResourceAnnotation] = req.NamespacedName.String()
-	managedObject.(controllerutil.Object).SetAnnotations(managedResourceAnnotations)
+	managedResourceAnnotations[utils.ManagedResourceAnnotation] = types.NamespacedName{Namespace: managedResource.Namespace, Name: managedResource.Name}.String()
+	if requester := managedResource.Requester(); requester != nil {
+		managedResourceAnnotations[utils.RequesterAnnotation] = requester.Username
+	}
+	object.(controllerutil.Object).SetAnnotations(managedResourceAnnotations)
 
 	// Try getting object from cluster
-	clusterObject := managedObject.DeepCopyObject()
-	if err = r.Client.Get(ctx, managedObjectKey, clusterObject); err != nil {
+	clusterObject := object.DeepCopyObject()
+	if err := r.Client.Get(ctx, managedObject.Key, clusterObject); err != nil {
+
+		if !apierrors.IsNotFound(err) {
+			return fail(paasv1beta1.ReasonApplyFailed, err)
+		}
+		clusterObject = nil
+	}
+
+	// Record out of band changes made since the object was last applied
+	r.recordDrift(managedResource, lastObjectStatus, managedObject.Object, clusterObject)
+
+	// Objects which may be created but not updated are write-once
+	updatePermitted := true
+	if clusterObject != nil {
+		if _, err := paasv1beta1.CheckPermissions(managedObject.Struct, managedObject.Object, utils.Namespace(managedResource.Namespace), managedResource.Requester(), utils.VerbUpdate); err != nil {
+			if !errors.Is(err, paasv1beta1.ErrPermissionDenied) {
+				return fail(paasv1beta1.ReasonApplyFailed, err)
+			}
+			updatePermitted = false
+		}
+	}
+
+	objectReason := paasv1beta1.ReasonApplied
+	switch {
+	case !updatePermitted:
 
-		if apierrors.IsNotFound(err) {
+		// Leave write-once objects as they are
+		object = clusterObject
+		objectReason = paasv1beta1.ReasonWriteOnce
 
-			// Create the managed object
-			if err := r.Client.Create(ctx, managedObject); err != nil {
-				log.Error(err)
-				return ctrl.Result{}, errors.New("an error occurred while trying to create the object: " + err.Error())
+	case managedResource.Spec.Apply.Mode == paasv1beta1.ApplyModeServerSide:
+
+		// Apply the managed object, creating it if needed
+		if err := r.Client.Patch(ctx, object, client.Apply, managedResource.Spec.Apply.PatchOptions()...); err != nil {
+			reason := paasv1beta1.ReasonApplyFailed
+			var conflicts []string
+			if apierrors.IsConflict(err) {
+				reason = paasv1beta1.ReasonFieldConflict
+				for _, conflict := range fieldConflicts(err) {
+					conflicts = append(conflicts, managedObject.String()+": "+conflict)
+				}
 			}
+			objectStatus, _, err := fail(reason, errors.New("an error occurred while trying to apply the object: "+err.Error()))
+			return objectStatus, conflicts, err
+		}
 
-		} else {
-			log.Error(err)
-			return ctrl.Result{}, err
+	case clusterObject == nil:
+
+		// Create the managed object
+		if err := r.Client.Create(ctx, object); err != nil {
+			return fail(paasv1beta1.ReasonApplyFailed, errors.New("an error occurred while trying to create the object: "+err.Error()))
 		}
 
-	} else {
+	default:
 
 		// Insert .metadata.resourceVersion field into managed object
-		managedObject.(controllerutil.Object).SetResourceVersion(clusterObject.(controllerutil.Object).GetResourceVersion())
+		object.(controllerutil.Object).SetResourceVersion(clusterObject.(controllerutil.Object).GetResourceVersion())
 
 		// Update the managed object
-		if err := r.Client.Update(ctx, managedObject); err != nil {
+		if err := r.Client.Update(ctx, object); err != nil {
+			return fail(paasv1beta1.ReasonApplyFailed, errors.New("an error occurred while trying to update the object: "+err.Error()))
+		}
+	}
+
+	// Watch the kind of the managed object to detect drift
+	if err := r.watchManagedKind(object.GetObjectKind().GroupVersionKind()); err != nil {
+		log.Error(err)
+	}
+
+	// Record the live managed object
+	objectStatus = newObjectStatus(object)
+	objectStatus.Reason = objectReason
+
+	return objectStatus, nil, nil
+}
+
+// pruneObjects deletes previously applied objects which were removed from the source and returns the ones which could not be deleted
+func (r *ManagedResourceReconciler) pruneObjects(ctx context.Context, managedResource *paasv1beta1.ManagedResource, managedObjects []utils.ManagedObject) ([]paasv1beta1.ManagedObjectStatus, error) {
+
+	// Identities of the objects which are still managed
+	managedIdentities := make(map[string]bool)
+	for _, managedObject := range managedObjects {
+		managedIdentities[managedObject.Identity()] = true
+	}
+
+	// Delete removed objects in reverse order
+	var pruneErr error
+	var failedObjectStatuses []paasv1beta1.ManagedObjectStatus
+	for i := len(managedResource.Status.Objects) - 1; i >= 0; i-- {
+		objectStatus := managedResource.Status.Objects[i]
+		if managedIdentities[objectStatus.Identity()] {
+			continue
+		}
+
+		if err := r.deleteObject(ctx, managedResource, objectStatus); err != nil {
+			err = errors.New("an error occurred while trying to prune " + objectStatus.Identity() + ": " + err.Error())
 			log.Error(err)
-			return ctrl.Result{}, errors.New("an error occurred while trying to update the object: " + err.Error())
+			objectStatus.Reason = paasv1beta1.ReasonPruneFailed
+			objectStatus.Message = paasv1beta1.TruncateMessage(err.Error())
+			failedObjectStatuses = append(failedObjectStatuses, objectStatus)
+			if pruneErr == nil {
+				pruneErr = err
+			}
 		}
 	}
 
-	// Update managed resource with finalizer field
-	if err := r.Update(context.Background(), managedResource); err != nil {
+	return failedObjectStatuses, pruneErr
+}
+
+// deleteObject deletes a previously applied object if it still belongs to the managed resource and the bindings permit it
+func (r *ManagedResourceReconciler) deleteObject(ctx context.Context, managedResource *paasv1beta1.ManagedResource, objectStatus paasv1beta1.ManagedObjectStatus) error {
+
+	owner := types.NamespacedName{Namespace: managedResource.Namespace, Name: managedResource.Name}.String()
+
+	object := &unstructured.Unstructured{}
+	object.SetAPIVersion(objectStatus.APIVersion)
+	object.SetKind(objectStatus.Kind)
+	err := r.Client.Get(ctx, types.NamespacedName{Namespace: objectStatus.Namespace, Name: objectStatus.Name}, object)
+	if err == nil && object.GetAnnotations()[utils.ManagedResourceAnnotation] == owner {
+		_, err = paasv1beta1.CheckPermissions(objectStatus.Struct(), nil, utils.Namespace(managedResource.Namespace), managedResource.Requester(), utils.VerbDelete)
+		if err == nil {
+			err = r.Client.Delete(ctx, object)
+		}
+	}
+
+	if err != nil && !apierrors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+		return err
+	}
+
+	return nil
+}
+
+// finalize deletes the managed objects recorded in the status in reverse order and removes the finalizer of the managed resource
+func (r *ManagedResourceReconciler) finalize(ctx context.Context, managedResource *paasv1beta1.ManagedResource) error {
+
+	// Report that the managed objects are being deleted
+	r.setCondition(managedResource, paasv1beta1.ConditionDeleting, paasv1beta1.ConditionTrue, paasv1beta1.ReasonFinalizing, "deleting the managed objects")
+	r.setCondition(managedResource, paasv1beta1.ConditionReady, paasv1beta1.ConditionFalse, paasv1beta1.ReasonFinalizing, "deleting the managed objects")
+	if err := r.updateStatus(ctx, managedResource, nil); err != nil {
+		return err
+	}
+
+	// Delete objects if they exist, leaving objects the bindings no longer permit to delete in place
+	for i := len(managedResource.Status.Objects) - 1; i >= 0; i-- {
+		objectStatus := managedResource.Status.Objects[i]
+		if err := r.deleteObject(ctx, managedResource, objectStatus); errors.Is(err, paasv1beta1.ErrPermissionDenied) {
+			log.Info("leaving " + objectStatus.Identity() + " in place: " + err.Error())
+		} else if err != nil {
+			err = errors.New("an error occurred while trying to delete " + objectStatus.Identity() + ": " + err.Error())
+			log.Error(err)
+			r.setFailed(managedResource, paasv1beta1.ConditionDeleting, paasv1beta1.ReasonDeleteFailed, err)
+			return r.updateStatus(ctx, managedResource, err)
+		}
+	}
+
+	// Update finalizers field for CR
+	controllerutil.RemoveFinalizer(managedResource, managedObjectFinalizer)
+	if err := r.Client.Update(ctx, managedResource); err != nil {
 		log.Error(err)
-		return ctrl.Result{}, err
+		return err
 	}
 
-	return ctrl.Result{}, nil
+	return nil
+}
+
+// recordDrift records out of band changes made to a managed object since it was last applied
+func (r *ManagedResourceReconciler) recordDrift(managedResource *paasv1beta1.ManagedResource, lastObjectStatus *paasv1beta1.ManagedObjectStatus, object runtime.Object, clusterObject runtime.Object) {
+
+	driftType := objectDrift(lastObjectStatus, object, clusterObject)
+	if driftType == "" {
+		return
+	}
+
+	if managedResource.Status.Drift == nil {
+		managedResource.Status.Drift = &paasv1beta1.DriftStatus{}
+	}
+	managedResource.Status.Drift.Type = driftType
+	managedResource.Status.Drift.LastDriftTime = metav1.Now()
+	managedResource.Status.Drift.Count++
+}
+
+// objectDrift returns the type of out of band change made to a managed object since it was last applied or an empty string if there is none
+func objectDrift(lastObjectStatus *paasv1beta1.ManagedObjectStatus, object runtime.Object, clusterObject runtime.Object) string {
+
+	// Nothing could have drifted if the object was never applied
+	if lastObjectStatus == nil || lastObjectStatus.UID == "" {
+		return ""
+	}
+
+	// Compare generations if the object tracks them and resource versions otherwise
+	if clusterObject == nil {
+		return paasv1beta1.DriftDeleted
+	} else if clusterObjectMeta := clusterObject.(controllerutil.Object); clusterObjectMeta.GetUID() != lastObjectStatus.UID {
+		return paasv1beta1.DriftDeleted
+	} else if clusterObjectMeta.GetGeneration() != 0 {
+		if clusterObjectMeta.GetGeneration() != lastObjectStatus.Generation {
+			return paasv1beta1.DriftModified
+		}
+	} else if clusterObjectMeta.GetResourceVersion() != lastObjectStatus.ResourceVersion {
+		return paasv1beta1.DriftModified
+	}
+
+	// Metadata changes do not bump the generation, so compare the labels and annotations the object sets, unless it is write-once and never reverted
+	if object != nil && lastObjectStatus.Reason != paasv1beta1.ReasonWriteOnce && metadataDrifted(object.(controllerutil.Object), clusterObject.(controllerutil.Object)) {
+		return paasv1beta1.DriftModified
+	}
+
+	return ""
+}
+
+// metadataDrifted checks whether any label or annotation of the managed object is missing from or differs in the cluster object
+func metadataDrifted(objectMeta controllerutil.Object, clusterObjectMeta controllerutil.Object) bool {
+
+	clusterLabels := clusterObjectMeta.GetLabels()
+	for key, value := range objectMeta.GetLabels() {
+		if clusterValue, ok := clusterLabels[key]; !ok || clusterValue != value {
+			return true
+		}
+	}
+
+	clusterAnnotations := clusterObjectMeta.GetAnnotations()
+	for key, value := range objectMeta.GetAnnotations() {
+		if clusterValue, ok := clusterAnnotations[key]; !ok || clusterValue != value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newObjectStatus returns the identity of a managed object
+func newObjectStatus(object runtime.Object) paasv1beta1.ManagedObjectStatus {
+	objectMeta := object.(controllerutil.Object)
+	objectAPIVersion, objectKind := object.GetObjectKind().GroupVersionKind().ToAPIVersionAndKind()
+
+	return paasv1beta1.ManagedObjectStatus{
+		ObjectReference: corev1.ObjectReference{
+			APIVersion:      objectAPIVersion,
+			Kind:            objectKind,
+			Name:            objectMeta.GetName(),
+			Namespace:       objectMeta.GetNamespace(),
+			UID:             objectMeta.GetUID(),
+			ResourceVersion: objectMeta.GetResourceVersion(),
+		},
+		Generation: objectMeta.GetGeneration(),
+	}
+}
+
+// findObjectStatus returns a copy of the status of the object with the given identity or nil if it is not present
+func findObjectStatus(objectStatuses []paasv1beta1.ManagedObjectStatus, identity string) *paasv1beta1.ManagedObjectStatus {
+
+	for i := range objectStatuses {
+		if objectStatuses[i].Identity() == identity {
+			objectStatus := objectStatuses[i]
+			return &objectStatus
+		}
+	}
+
+	return nil
+}
+
+// watchManagedKind watches objects of the given kind and enqueues the managed resources which own them
+func (r *ManagedResourceReconciler) watchManagedKind(gvk schema.GroupVersionKind) error {
+	r.watchedKindsMux.Lock()
+	defer r.watchedKindsMux.Unlock()
+
+	if r.watchedKinds[gvk] || !utils.CurrentFeatures().DriftDetectionEnabled() {
+		return nil
+	}
+
+	// Get the kind informer, giving up if it does not sync in time (e.g. if the operator may not list the kind)
+	watchedObject := &unstructured.Unstructured{}
+	watchedObject.SetGroupVersionKind(gvk)
+	ctx, cancel := context.WithTimeout(context.Background(), informerSyncTimeout)
+	defer cancel()
+	informer, err := r.cache.GetInformer(ctx, watchedObject)
+	if err != nil {
+		return errors.New("an error occurred while trying to watch " + gvk.String() + ": " + err.Error())
+	}
+
+	// Enqueue the owner of a managed object when its spec or metadata changes or it is deleted
+	if err := r.controller.Watch(
+		&source.Informer{Informer: informer},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(managedObjectOwner)},
+		predicate.Funcs{
+			CreateFunc: func(e event.CreateEvent) bool {
+				return false
+			},
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				return e.MetaNew.GetGeneration() == 0 || e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration() ||
+					!reflect.DeepEqual(e.MetaOld.GetLabels(), e.MetaNew.GetLabels()) || !reflect.DeepEqual(e.MetaOld.GetAnnotations(), e.MetaNew.GetAnnotations())
+			},
+		},
+	); err != nil {
+		return errors.New("an error occurred while trying to watch " + gvk.String() + ": " + err.Error())
+	}
+
+	r.watchedKinds[gvk] = true
+	return nil
+}
+
+// managedObjectOwner maps a managed object to the managed resource referenced by its owner annotation
+func managedObjectOwner(object handler.MapObject) []reconcile.Request {
+
+	// Kinds which are already watched are ignored once drift detection is disabled
+	if !utils.CurrentFeatures().DriftDetectionEnabled() {
+		return nil
+	}
+
+	owner, ok := object.Meta.GetAnnotations()[utils.ManagedResourceAnnotation]
+	if !ok {
+		return nil
+	}
+
+	ownerParts := strings.SplitN(owner, string(types.Separator), 2)
+	if len(ownerParts) != 2 {
+		return nil
+	}
+
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Namespace: ownerParts[0], Name: ownerParts[1]},
+	}}
+}
+
+// sourceReferrers maps a config map or secret to the managed resources which use it as their source
+func (r *ManagedResourceReconciler) sourceReferrers(index string) handler.ToRequestsFunc {
+	return func(object handler.MapObject) []reconcile.Request {
+
+		// List managed resources which reference the object
+		managedResources := &paasv1beta1.ManagedResourceList{}
+		if err := r.List(context.Background(), managedResources, client.InNamespace(object.Meta.GetNamespace()), client.MatchingFields{index: object.Meta.GetName()}); err != nil {
+			log.Error(err)
+			return nil
+		}
+
+		requests := make([]reconcile.Request, 0, len(managedResources.Items))
+		for _, managedResource := range managedResources.Items {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: managedResource.Namespace, Name: managedResource.Name},
+			})
+		}
+
+		return requests
+	}
+}
+
+// setCondition sets a condition for the current generation of the managed resource
+func (r *ManagedResourceReconciler) setCondition(managedResource *paasv1beta1.ManagedResource, conditionType string, status paasv1beta1.ConditionStatus, reason string, message string) {
+	paasv1beta1.SetCondition(&managedResource.Status.Conditions, paasv1beta1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		ObservedGeneration: managedResource.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// setFailed marks the given condition and the Ready condition as failed with the given error
+func (r *ManagedResourceReconciler) setFailed(managedResource *paasv1beta1.ManagedResource, conditionType string, reason string, err error) {
+
+	// Deleting condition stays true while the deletion is failing
+	conditionStatus := paasv1beta1.ConditionFalse
+	if conditionType == paasv1beta1.ConditionDeleting {
+		conditionStatus = paasv1beta1.ConditionTrue
+	}
+
+	r.setCondition(managedResource, conditionType, conditionStatus, reason, err.Error())
+	r.setCondition(managedResource, paasv1beta1.ConditionReady, paasv1beta1.ConditionFalse, reason, err.Error())
+	managedResource.Status.ObservedGeneration = managedResource.Generation
+	managedResource.Status.LastError = paasv1beta1.TruncateMessage(err.Error())
+}
+
+// setSynced marks the managed resource as ready
+func (r *ManagedResourceReconciler) setSynced(managedResource *paasv1beta1.ManagedResource) {
+	now := metav1.Now()
+
+	r.setCondition(managedResource, paasv1beta1.ConditionSynced, paasv1beta1.ConditionTrue, paasv1beta1.ReasonApplied, "")
+	r.setCondition(managedResource, paasv1beta1.ConditionReady, paasv1beta1.ConditionTrue, paasv1beta1.ReasonReconciled, "")
+	paasv1beta1.RemoveCondition(&managedResource.Status.Conditions, paasv1beta1.ConditionDeleting)
+	managedResource.Status.ObservedGeneration = managedResource.Generation
+	managedResource.Status.LastSyncTime = &now
+	managedResource.Status.LastError = ""
+}
+
+// sourceErrorReason returns the reason the source could not be read for, telling apart rejected URL responses
+func sourceErrorReason(err error) string {
+
+	var fetchErr *utils.FetchError
+	if errors.As(err, &fetchErr) {
+		return fetchErr.Reason
+	}
+
+	return paasv1beta1.ReasonSourceError
+}
+
+// fieldConflicts lists the field ownership conflicts reported by a server-side apply error
+func fieldConflicts(err error) []string {
+
+	var conflicts []string
+	if status, ok := err.(apierrors.APIStatus); ok && status.Status().Details != nil {
+		for _, cause := range status.Status().Details.Causes {
+			conflicts = append(conflicts, cause.Message)
+		}
+	}
+
+	return conflicts
+}
+
+// updateStatus writes the managed resource status and passes the reconciliation error through
+func (r *ManagedResourceReconciler) updateStatus(ctx context.Context, managedResource *paasv1beta1.ManagedResource, reconcileErr error) error {
+
+	if err := r.Status().Update(ctx, managedResource); err != nil {
+		log.Error(err)
+		if reconcileErr == nil {
+			return err
+		}
+	}
+
+	return reconcileErr
 }
 
 // SetupWithManager registers controller with the manager
 func (r *ManagedResourceReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&paasv1beta1.ManagedResource{}).
-		Complete(r)
+	r.cache = mgr.GetCache()
+	r.watchedKinds = make(map[schema.GroupVersionKind]bool)
+	r.limiter = newReconcileLimiter(paasv1beta1.DefaultMaxConcurrentReconciles)
+	r.reconcileInterval = paasv1beta1.DefaultReconcileInterval
+
+	// Index managed resources by the config maps and secrets they reference
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &paasv1beta1.ManagedResource{}, configMapRefIndex, func(object runtime.Object) []string {
+		if configMapRef := object.(*paasv1beta1.ManagedResource).Spec.Source.ConfigMapRef; configMapRef != nil {
+			return []string{configMapRef.Name}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &paasv1beta1.ManagedResource{}, secretRefIndex, func(object runtime.Object) []string {
+		if secretRef := object.(*paasv1beta1.ManagedResource).Spec.Source.SecretRef; secretRef != nil {
+			return []string{secretRef.Name}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		For(&paasv1beta1.ManagedResource{}, builder.WithPredicates(predicate.Funcs{
+
+			// Ignore updates which leave the spec intact, such as status updates, but keep periodic resyncs
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				return e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration() ||
+					e.MetaOld.GetResourceVersion() == e.MetaNew.GetResourceVersion()
+			},
+		})).
+		Watches(
+			&source.Kind{Type: &corev1.ConfigMap{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: r.sourceReferrers(configMapRefIndex)},
+			builder.WithPredicates(predicate.Funcs{
+
+				// Re-apply only when the data of the config map changes
+				UpdateFunc: func(e event.UpdateEvent) bool {
+					oldConfigMap, newConfigMap := e.ObjectOld.(*corev1.ConfigMap), e.ObjectNew.(*corev1.ConfigMap)
+					return !reflect.DeepEqual(oldConfigMap.Data, newConfigMap.Data) || !reflect.DeepEqual(oldConfigMap.BinaryData, newConfigMap.BinaryData)
+				},
+			}),
+		).
+		Watches(
+			&source.Kind{Type: &corev1.Secret{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: r.sourceReferrers(secretRefIndex)},
+			builder.WithPredicates(predicate.Funcs{
+
+				// Re-apply only when the data of the secret changes
+				UpdateFunc: func(e event.UpdateEvent) bool {
+					return !reflect.DeepEqual(e.ObjectOld.(*corev1.Secret).Data, e.ObjectNew.(*corev1.Secret).Data)
+				},
+			}),
+		).
+		WithOptions(controller.Options{
+
+			// Concurrency is limited by the operator config instead, since it may change at any time
+			MaxConcurrentReconciles: paasv1beta1.MaxConcurrentReconcilesLimit,
+		}).
+		Build(r)
+	if err != nil {
+		return err
+	}
+
+	r.controller = c
+	return nil
 }
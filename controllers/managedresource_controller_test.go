@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	paasv1beta1 "operator/api/v1beta1"
+)
+
+func TestObjectDrift(t *testing.T) {
+
+	// A managed object which tracks generations
+	newObject := func(generation int64, labels map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:            "test",
+			UID:             types.UID("uid"),
+			ResourceVersion: "2",
+			Generation:      generation,
+			Labels:          labels,
+		}}
+	}
+	lastObjectStatus := &paasv1beta1.ManagedObjectStatus{
+		ObjectReference: corev1.ObjectReference{UID: types.UID("uid"), ResourceVersion: "1"},
+		Generation:      1,
+		Reason:          paasv1beta1.ReasonApplied,
+	}
+	writeOnceObjectStatus := lastObjectStatus.DeepCopy()
+	writeOnceObjectStatus.Reason = paasv1beta1.ReasonWriteOnce
+
+	desired := newObject(0, map[string]string{"app": "test"})
+
+	tests := map[string]struct {
+		lastObjectStatus *paasv1beta1.ManagedObjectStatus
+		clusterObject    runtime.Object
+		expected         string
+	}{
+		"never applied":            {nil, nil, ""},
+		"deleted":                  {lastObjectStatus, nil, paasv1beta1.DriftDeleted},
+		"unchanged":                {lastObjectStatus, newObject(1, map[string]string{"app": "test", "extra": "label"}), ""},
+		"spec changed":             {lastObjectStatus, newObject(2, map[string]string{"app": "test"}), paasv1beta1.DriftModified},
+		"label changed":            {lastObjectStatus, newObject(1, map[string]string{"app": "other"}), paasv1beta1.DriftModified},
+		"label removed":            {lastObjectStatus, newObject(1, nil), paasv1beta1.DriftModified},
+		"write-once label changed": {writeOnceObjectStatus, newObject(1, nil), ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if drift := objectDrift(test.lastObjectStatus, desired, test.clusterObject); drift != test.expected {
+				t.Errorf("expected drift %q, got %q", test.expected, drift)
+			}
+		})
+	}
+}
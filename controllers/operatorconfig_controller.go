@@ -0,0 +1,185 @@
+/*
+Copyright 2020 Vladislav Poberezhny.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/common/log"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	paasv1beta1 "operator/api/v1beta1"
+
+	"operator/pkg/utils"
+)
+
+// OperatorConfigReconciler reconciles an OperatorConfig object
+type OperatorConfigReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+
+	// ManagedResources is the reconciler which runs with the reconciliation settings of the config
+	ManagedResources *ManagedResourceReconciler
+
+	// LegacyConfig is read from the deprecated environment variables and used for the fields the config leaves unset
+	LegacyConfig paasv1beta1.OperatorConfigSpec
+
+	effective paasv1beta1.OperatorConfigSpec
+}
+
+// +kubebuilder:rbac:groups=paas.il,resources=operatorconfigs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=paas.il,resources=operatorconfigs/status,verbs=get;update;patch
+
+// Reconcile applies the received operator config
+func (r *OperatorConfigReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	_ = r.Log.WithValues("operatorconfig", req.NamespacedName)
+
+	// Get operator config k8s object, falling back to the defaults if it was deleted
+	operatorConfig := &paasv1beta1.OperatorConfig{}
+	if err := r.Get(ctx, req.NamespacedName, operatorConfig); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error(err)
+			return ctrl.Result{}, err
+		}
+		if req.Name == paasv1beta1.OperatorConfigName {
+			r.apply(paasv1beta1.OperatorConfigSpec{})
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Only a single operator config is used
+	if operatorConfig.Name != paasv1beta1.OperatorConfigName {
+		r.setCondition(operatorConfig, paasv1beta1.ConditionFalse, paasv1beta1.ReasonIgnored, "only the operator config named "+paasv1beta1.OperatorConfigName+" is used")
+		operatorConfig.Status.Effective = nil
+		return ctrl.Result{}, r.updateStatus(ctx, operatorConfig)
+	}
+
+	// Keep running with the current config if the new one is invalid
+	if err := operatorConfig.Spec.Validate(); err != nil {
+		log.Error(err)
+		r.setCondition(operatorConfig, paasv1beta1.ConditionFalse, paasv1beta1.ReasonInvalidConfig, err.Error())
+	} else {
+		r.apply(operatorConfig.Spec)
+		r.setCondition(operatorConfig, paasv1beta1.ConditionTrue, paasv1beta1.ReasonConfigApplied, "")
+	}
+
+	// Report the config the operator runs with
+	effective := r.effective
+	operatorConfig.Status.Effective = &effective
+
+	return ctrl.Result{}, r.updateStatus(ctx, operatorConfig)
+}
+
+// apply makes the operator run with the given config
+func (r *OperatorConfigReconciler) apply(spec paasv1beta1.OperatorConfigSpec) {
+
+	r.effective = withLegacyConfig(spec, r.LegacyConfig).WithDefaults()
+	r.ManagedResources.configure(r.effective.ReconcileInterval.Duration, r.effective.MaxConcurrentReconciles)
+}
+
+// loadSourceConfig configures how sources are read before the webhooks are served and keeps it up to date, as every replica serves the webhooks while only the leader reconciles
+func (r *OperatorConfigReconciler) loadSourceConfig(mgr ctrl.Manager) error {
+
+	// Read the current config directly, as the cache is not started yet, starting from the legacy config if it is missing or invalid
+	utils.Configure(r.LegacyConfig.HTTP, utils.FeaturesConfig{}, r.LegacyConfig.Signatures)
+	operatorConfig := &paasv1beta1.OperatorConfig{}
+	if err := mgr.GetAPIReader().Get(context.Background(), types.NamespacedName{Name: paasv1beta1.OperatorConfigName}, operatorConfig); err == nil {
+		r.configureSources(operatorConfig)
+	} else if !apierrors.IsNotFound(err) {
+		log.Error(err)
+	}
+
+	// Follow changes of the config on every replica
+	informer, err := mgr.GetCache().GetInformer(context.Background(), &paasv1beta1.OperatorConfig{})
+	if err != nil {
+		return err
+	}
+	informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: r.configureSources,
+		UpdateFunc: func(_, object interface{}) {
+			r.configureSources(object)
+		},
+		DeleteFunc: func(object interface{}) {
+			if tombstone, ok := object.(toolscache.DeletedFinalStateUnknown); ok {
+				object = tombstone.Obj
+			}
+			if operatorConfig, ok := object.(*paasv1beta1.OperatorConfig); ok && operatorConfig.Name == paasv1beta1.OperatorConfigName {
+				utils.Configure(r.LegacyConfig.HTTP, utils.FeaturesConfig{}, r.LegacyConfig.Signatures)
+			}
+		},
+	})
+
+	return nil
+}
+
+// configureSources makes the sources be read with the operator config, keeping the current configuration if it is invalid which the reconciler reports
+func (r *OperatorConfigReconciler) configureSources(object interface{}) {
+
+	operatorConfig, ok := object.(*paasv1beta1.OperatorConfig)
+	if !ok || operatorConfig.Name != paasv1beta1.OperatorConfigName || operatorConfig.Spec.Validate() != nil {
+		return
+	}
+
+	spec := withLegacyConfig(operatorConfig.Spec, r.LegacyConfig)
+	utils.Configure(spec.HTTP, spec.Features, spec.Signatures)
+}
+
+// setCondition sets the Ready condition for the current generation of the operator config
+func (r *OperatorConfigReconciler) setCondition(operatorConfig *paasv1beta1.OperatorConfig, status paasv1beta1.ConditionStatus, reason string, message string) {
+	paasv1beta1.SetCondition(&operatorConfig.Status.Conditions, paasv1beta1.Condition{
+		Type:               paasv1beta1.ConditionReady,
+		Status:             status,
+		ObservedGeneration: operatorConfig.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+	operatorConfig.Status.ObservedGeneration = operatorConfig.Generation
+}
+
+// updateStatus writes the operator config status
+func (r *OperatorConfigReconciler) updateStatus(ctx context.Context, operatorConfig *paasv1beta1.OperatorConfig) error {
+
+	if err := r.Status().Update(ctx, operatorConfig); err != nil {
+		log.Error(err)
+		return err
+	}
+
+	return nil
+}
+
+// SetupWithManager registers controller with the manager
+func (r *OperatorConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.apply(paasv1beta1.OperatorConfigSpec{})
+
+	if err := r.loadSourceConfig(mgr); err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&paasv1beta1.OperatorConfig{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2020 Vladislav Poberezhny.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sync"
+)
+
+// reconcileLimiter limits the number of reconciliations which run at the same time to a limit which may be changed at any time
+type reconcileLimiter struct {
+	limit  int
+	active int
+	cond   *sync.Cond
+}
+
+// newReconcileLimiter returns a limiter which allows the given number of reconciliations at the same time
+func newReconcileLimiter(limit int) *reconcileLimiter {
+	return &reconcileLimiter{
+		limit: limit,
+		cond:  sync.NewCond(&sync.Mutex{}),
+	}
+}
+
+// acquire waits until another reconciliation may run
+func (l *reconcileLimiter) acquire() {
+	l.cond.L.Lock()
+	defer l.cond.L.Unlock()
+
+	for l.active >= l.limit {
+		l.cond.Wait()
+	}
+	l.active++
+}
+
+// release marks a reconciliation as done
+func (l *reconcileLimiter) release() {
+	l.cond.L.Lock()
+	defer l.cond.L.Unlock()
+
+	l.active--
+	l.cond.Broadcast()
+}
+
+// setLimit changes the number of reconciliations which may run at the same time
+func (l *reconcileLimiter) setLimit(limit int) {
+	l.cond.L.Lock()
+	defer l.cond.L.Unlock()
+
+	l.limit = limit
+	l.cond.Broadcast()
+}
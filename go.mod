@@ -3,13 +3,20 @@ module operator
 go 1.13
 
 require (
-	github.com/fatih/structs v1.1.0
+	github.com/evanphx/json-patch v4.5.0+incompatible
+	github.com/go-git/go-git/v5 v5.1.0
 	github.com/go-logr/logr v0.1.0
+	github.com/go-openapi/validate v0.19.5
 	github.com/imdario/mergo v0.3.9
 	github.com/jeremywohl/flatten v1.0.1
 	github.com/onsi/ginkgo v1.12.1
 	github.com/onsi/gomega v1.10.1
+	github.com/prometheus/client_golang v1.0.0
 	github.com/prometheus/common v0.4.1
+	golang.org/x/crypto v0.0.0-20200302210943-78000ba7a073
+	golang.org/x/net v0.0.0-20200520004742-59133d7f0dd7
+	k8s.io/api v0.18.6
+	k8s.io/apiextensions-apiserver v0.18.6
 	k8s.io/apimachinery v0.18.6
 	k8s.io/client-go v0.18.6
 	sigs.k8s.io/controller-runtime v0.6.2
@@ -19,8 +19,6 @@ package main
 import (
 	"flag"
 	"os"
-	"strconv"
-	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -57,43 +55,54 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
 
-	// Try parsing reconciliation period from environment variable
-	const envReconciliationIntervalMS = "RECONCILIATION_INTERVAL_MS"
-	defaultSyncPeriodMS := uint64(60000)
-	syncPeriodMS, err := strconv.ParseUint(os.Getenv(envReconciliationIntervalMS), 10, 32)
-	if err != nil {
-		setupLog.Info("could not parse " + envReconciliationIntervalMS + " environment variable, falling back to " + strconv.Itoa(int(defaultSyncPeriodMS)))
-		syncPeriodMS = defaultSyncPeriodMS
-	}
-	syncPeriod := time.Duration(syncPeriodMS) * time.Millisecond
-
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: metricsAddr,
 		Port:               9443,
 		LeaderElection:     enableLeaderElection,
 		LeaderElectionID:   "fbd4eefd.il",
-		SyncPeriod:         &syncPeriod,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	if err = (&controllers.ManagedResourceReconciler{
+	managedResourceReconciler := &controllers.ManagedResourceReconciler{
 		Client: mgr.GetClient(),
 		Log:    ctrl.Log.WithName("controllers").WithName("ManagedResource"),
 		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
+	}
+	if err = managedResourceReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ManagedResource")
 		os.Exit(1)
 	}
 
+	legacyConfig, err := controllers.LegacyConfigFromEnvironment(setupLog)
+	if err != nil {
+		setupLog.Error(err, "unable to read deprecated environment variables")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.OperatorConfigReconciler{
+		Client:           mgr.GetClient(),
+		Log:              ctrl.Log.WithName("controllers").WithName("OperatorConfig"),
+		Scheme:           mgr.GetScheme(),
+		ManagedResources: managedResourceReconciler,
+		LegacyConfig:     legacyConfig,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "OperatorConfig")
+		os.Exit(1)
+	}
+
 	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
 		if err = (&paasv1beta1.ManagedResource{}).SetupWebhookWithManager(mgr); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "ManagedResource")
 			os.Exit(1)
 		}
+		if err = (&paasv1beta1.ManagedResourceBinding{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ManagedResourceBinding")
+			os.Exit(1)
+		}
 	}
 	// +kubebuilder:scaffold:builder
 
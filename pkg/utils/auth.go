@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Keys of a URL credentials secret
+const (
+	URLUsernameKey     = "username"
+	URLPasswordKey     = "password"
+	URLTokenKey        = "token"
+	URLHeaderKeyPrefix = "header."
+)
+
+// CredentialsLabel opts a secret in to be read by the operator as the credentials of URL and git sources, other secrets of the namespace are never read
+const CredentialsLabel = "managedresources.paas.il/credentials"
+
+// SourceLabel opts a secret in to be read by the operator as the source of managed resources, as its contents may otherwise be revealed through errors and the managed objects
+const SourceLabel = "managedresources.paas.il/source"
+
+// maxRedirects is the number of redirects a source request follows, like the default HTTP client
+const maxRedirects = 10
+
+// URLAuth is a reference to the credentials of a URL source
+type URLAuth struct {
+
+	// SecretName is the name of a secret within the managed resource namespace holding either a username and password or a bearer token, along with header.<name> keys of custom headers, which must be labeled managedresources.paas.il/credentials=true
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern="^[a-z0-9]([-a-z0-9]*[a-z0-9])?([.][a-z0-9]([-a-z0-9]*[a-z0-9])?)*$"
+	SecretName string `json:"secretName"`
+}
+
+// authHeaders reads the headers which authenticate requests of a URL source from its credentials secret
+func authHeaders(sourceStruct SourceStruct, sourceContext SourceContext) (http.Header, error) {
+
+	headers := http.Header{}
+	if sourceStruct.Auth == nil {
+		return headers, nil
+	}
+
+	// Get referenced secret, never including its contents in errors
+	secret, err := getCredentialsSecret(sourceContext, sourceStruct.Auth.SecretName)
+	if err != nil {
+		return nil, errors.New("an error occurred while reading URL credentials secret " + sourceStruct.Auth.SecretName + ": " + err.Error())
+	}
+
+	// Custom headers
+	for key, value := range secret.Data {
+		if strings.HasPrefix(key, URLHeaderKeyPrefix) && len(key) > len(URLHeaderKeyPrefix) {
+			headers.Set(strings.TrimPrefix(key, URLHeaderKeyPrefix), string(value))
+		}
+	}
+
+	// Bearer token takes precedence over basic auth
+	if token, ok := secret.Data[URLTokenKey]; ok {
+		headers.Set("Authorization", "Bearer "+string(token))
+	} else if username, ok := secret.Data[URLUsernameKey]; ok {
+		credentials := string(username) + ":" + string(secret.Data[URLPasswordKey])
+		headers.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+
+	return headers, nil
+}
+
+// newAuthenticatedRequest builds a GET request for the URL with the given authentication headers
+func newAuthenticatedRequest(url string, headers http.Header) (*http.Request, error) {
+
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, values := range headers {
+		request.Header[name] = append([]string(nil), values...)
+	}
+
+	return request, nil
+}
+
+// getCredentialsSecret reads a secret which opted in to be used as source credentials, as the operator could otherwise send any secret of the namespace, such as service account tokens, to a URL of the tenant's choice
+func getCredentialsSecret(sourceContext SourceContext, name string) (*corev1.Secret, error) {
+
+	secret := &corev1.Secret{}
+	if err := sourceContext.Client.Get(context.Background(), types.NamespacedName{Namespace: sourceContext.Namespace, Name: name}, secret); err != nil {
+		return nil, err
+	}
+
+	if secret.Type == corev1.SecretTypeServiceAccountToken {
+		return nil, errors.New("service account token secrets may not be used as credentials")
+	}
+	if secret.Labels[CredentialsLabel] != "true" {
+		return nil, errors.New("secret must be labeled " + CredentialsLabel + "=true to be used as credentials")
+	}
+
+	return secret, nil
+}
+
+// sameOrigin checks whether both URLs have the same scheme and host, so credentials of one may be sent to the other
+func sameOrigin(a *url.URL, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host)
+}
+
+// credentialsRedirectPolicy removes the credential headers from redirects leaving the origin of the original request, which the default policy only does for some headers
+func credentialsRedirectPolicy(headers http.Header) func(*http.Request, []*http.Request) error {
+	return func(request *http.Request, via []*http.Request) error {
+
+		if len(via) >= maxRedirects {
+			return errors.New("stopped after " + strconv.Itoa(maxRedirects) + " redirects")
+		}
+
+		if !sameOrigin(request.URL, via[0].URL) {
+			for name := range headers {
+				request.Header.Del(name)
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGetCredentialsSecret(t *testing.T) {
+
+	secret := func(name string, secretType corev1.SecretType, labels map[string]string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "tenant", Name: name, Labels: labels},
+			Type:       secretType,
+			Data:       map[string][]byte{URLTokenKey: []byte("token")},
+		}
+	}
+	optedIn := map[string]string{CredentialsLabel: "true"}
+
+	sourceContext := SourceContext{
+		Namespace: "tenant",
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme,
+			secret("labeled", corev1.SecretTypeOpaque, optedIn),
+			secret("unlabeled", corev1.SecretTypeOpaque, nil),
+			secret("disabled", corev1.SecretTypeOpaque, map[string]string{CredentialsLabel: "false"}),
+			secret("service-account-token", corev1.SecretTypeServiceAccountToken, optedIn),
+		),
+	}
+
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "labeled"},
+		{name: "unlabeled", wantErr: true},
+		{name: "disabled", wantErr: true},
+		{name: "service-account-token", wantErr: true},
+		{name: "missing", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := getCredentialsSecret(sourceContext, test.name)
+			if (err != nil) != test.wantErr {
+				t.Errorf("getCredentialsSecret() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestCredentialsRedirectPolicy(t *testing.T) {
+
+	headers := http.Header{}
+	headers.Set("Authorization", "Bearer token")
+	headers.Set("X-Api-Key", "key")
+
+	// Record the credentials each server receives
+	received := map[string]http.Header{}
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received["other"] = r.Header.Clone()
+	}))
+	defer other.Close()
+	var origin *httptest.Server
+	origin = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/same":
+			http.Redirect(w, r, origin.URL+"/target", http.StatusFound)
+		case "/other":
+			http.Redirect(w, r, other.URL+"/target", http.StatusFound)
+		default:
+			received["origin"] = r.Header.Clone()
+		}
+	}))
+	defer origin.Close()
+
+	tests := []struct {
+		path        string
+		server      string
+		credentials bool
+	}{
+		{path: "/same", server: "origin", credentials: true},
+		{path: "/other", server: "other", credentials: false},
+	}
+
+	client := &http.Client{CheckRedirect: credentialsRedirectPolicy(headers)}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+
+			request, err := newAuthenticatedRequest(origin.URL+test.path, headers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response, err := client.Do(request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			response.Body.Close()
+
+			for name := range headers {
+				if got := received[test.server].Get(name) != ""; got != test.credentials {
+					t.Errorf("%s sent to %s: %v, want %v", name, test.server, got, test.credentials)
+				}
+			}
+		})
+	}
+}
+
+func TestSignatureHeaders(t *testing.T) {
+
+	headers := http.Header{}
+	headers.Set("Authorization", "Bearer token")
+
+	tests := []struct {
+		name         string
+		sourceURL    string
+		signatureURL string
+		credentials  bool
+	}{
+		{name: "same origin", sourceURL: "https://example.com/object.yaml", signatureURL: "https://example.com/object.yaml.sig", credentials: true},
+		{name: "host case", sourceURL: "https://example.com/object.yaml", signatureURL: "https://EXAMPLE.com/signatures/object.sig", credentials: true},
+		{name: "other host", sourceURL: "https://example.com/object.yaml", signatureURL: "https://attacker.example.org/object.sig", credentials: false},
+		{name: "subdomain", sourceURL: "https://example.com/object.yaml", signatureURL: "https://sub.example.com/object.sig", credentials: false},
+		{name: "other port", sourceURL: "https://example.com/object.yaml", signatureURL: "https://example.com:8443/object.sig", credentials: false},
+		{name: "downgraded scheme", sourceURL: "https://example.com/object.yaml", signatureURL: "http://example.com/object.sig", credentials: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := signatureHeaders(test.sourceURL, test.signatureURL, headers).Get("Authorization") != ""
+			if got != test.credentials {
+				t.Errorf("credentials sent: %v, want %v", got, test.credentials)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// kindOrder is the order in which kinds are applied, kinds which are not listed are applied last
+var kindOrder = []string{
+	"CustomResourceDefinition",
+	"Namespace",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"Secret",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Role",
+	"RoleBinding",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"Ingress",
+	"APIService",
+	"MutatingWebhookConfiguration",
+	"ValidatingWebhookConfiguration",
+}
+
+// splitDocuments splits multi-document YAML into single object documents, expanding lists into their items
+func splitDocuments(manifestBytes []byte) ([][]byte, error) {
+
+	var documents [][]byte
+
+	// Read YAML documents one by one
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(manifestBytes)))
+	for {
+		document, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.New("an error occurred while trying to split documents: " + err.Error())
+		}
+
+		// Skip empty documents
+		documentJSON, err := yaml.YAMLToJSON(document)
+		if err != nil {
+			return nil, errors.New("an error occurred while trying to read document: " + err.Error())
+		}
+		if documentJSON = bytes.TrimSpace(documentJSON); len(documentJSON) == 0 || string(documentJSON) == "null" {
+			continue
+		}
+
+		// Expand lists into their items
+		documentMap := map[string]interface{}{}
+		if err := json.Unmarshal(documentJSON, &documentMap); err != nil {
+			return nil, errors.New("an error occurred while trying to read document: " + err.Error())
+		}
+		kind, _ := documentMap["kind"].(string)
+		items, isList := documentMap["items"].([]interface{})
+		if !isList || !strings.HasSuffix(kind, "List") {
+			documents = append(documents, document)
+			continue
+		}
+
+		for _, item := range items {
+			itemBytes, err := yaml.Marshal(item)
+			if err != nil {
+				return nil, errors.New("an error occurred while trying to read list item: " + err.Error())
+			}
+			documents = append(documents, itemBytes)
+		}
+	}
+
+	return documents, nil
+}
+
+// kindPriority returns the position of the kind within the apply order
+func kindPriority(kind string) int {
+
+	for priority, orderedKind := range kindOrder {
+		if orderedKind == kind {
+			return priority
+		}
+	}
+
+	return len(kindOrder)
+}
+
+// sortManagedObjects sorts objects in the order they should be applied, keeping the source order within the same kind
+func sortManagedObjects(managedObjects []ManagedObject) {
+	sort.SliceStable(managedObjects, func(i, j int) bool {
+		return kindPriority(managedObjects[i].Struct.Kind) < kindPriority(managedObjects[j].Struct.Kind)
+	})
+}
+
+// DefinesKind checks whether any of the objects is a CustomResourceDefinition for the given kind
+func DefinesKind(managedObjects []ManagedObject, gvk schema.GroupVersionKind) bool {
+
+	for _, managedObject := range managedObjects {
+
+		object, ok := managedObject.Object.(*unstructured.Unstructured)
+		if !ok || object.GroupVersionKind().GroupKind() != (schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}) {
+			continue
+		}
+
+		group, _, _ := unstructured.NestedString(object.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(object.Object, "spec", "names", "kind")
+		if group == gvk.Group && kind == gvk.Kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// InlineObjects converts object documents to an embedded object, wrapping multiple objects with a list
+func InlineObjects(documents [][]byte) (runtime.RawExtension, error) {
+
+	// Embed a single object as is
+	if len(documents) == 1 {
+		documentJSON, err := yaml.YAMLToJSON(documents[0])
+		if err != nil {
+			return runtime.RawExtension{}, errors.New("an error occurred while trying to convert object: " + err.Error())
+		}
+
+		return runtime.RawExtension{Raw: documentJSON}, nil
+	}
+
+	// Collect all objects as list items
+	items := make([]interface{}, 0, len(documents))
+	for _, document := range documents {
+		var item map[string]interface{}
+		if err := yaml.Unmarshal(document, &item); err != nil {
+			return runtime.RawExtension{}, errors.New("an error occurred while trying to convert object: " + err.Error())
+		}
+		items = append(items, item)
+	}
+
+	listJSON, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items":      items,
+	})
+	if err != nil {
+		return runtime.RawExtension{}, errors.New("an error occurred while trying to convert object list: " + err.Error())
+	}
+
+	return runtime.RawExtension{Raw: listJSON}, nil
+}
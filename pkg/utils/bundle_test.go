@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSplitDocuments(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		manifest  string
+		documents []string
+		wantErr   bool
+	}{
+		{name: "empty", manifest: ""},
+		{name: "single", manifest: "kind: ConfigMap\nmetadata:\n  name: a\n", documents: []string{"name: a"}},
+		{
+			name:      "multiple",
+			manifest:  "kind: ConfigMap\nmetadata:\n  name: a\n---\nkind: Secret\nmetadata:\n  name: b\n",
+			documents: []string{"name: a", "name: b"},
+		},
+		{
+			name:      "empty documents",
+			manifest:  "---\n# comment only\n---\nkind: ConfigMap\nmetadata:\n  name: a\n---\n---\nnull\n",
+			documents: []string{"name: a"},
+		},
+		{
+			name:      "list",
+			manifest:  "apiVersion: v1\nkind: List\nitems:\n- kind: ConfigMap\n  metadata:\n    name: a\n- kind: Secret\n  metadata:\n    name: b\n",
+			documents: []string{"name: a", "name: b"},
+		},
+		{
+			name:      "typed list",
+			manifest:  "apiVersion: v1\nkind: ConfigMapList\nitems:\n- kind: ConfigMap\n  metadata:\n    name: a\n---\nkind: Secret\nmetadata:\n  name: b\n",
+			documents: []string{"name: a", "name: b"},
+		},
+		{name: "empty list", manifest: "apiVersion: v1\nkind: List\nitems: []\n"},
+		{
+			name:      "items of a non list kind",
+			manifest:  "kind: Inventory\nitems:\n- name: a\n",
+			documents: []string{"kind: Inventory"},
+		},
+		{name: "invalid YAML", manifest: "kind: ConfigMap\nmetadata: [\n", wantErr: true},
+		{name: "not an object", manifest: "- kind: ConfigMap\n", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			documents, err := splitDocuments([]byte(test.manifest))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("splitDocuments() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if len(documents) != len(test.documents) {
+				t.Fatalf("splitDocuments() returned %d documents, want %d: %q", len(documents), len(test.documents), documents)
+			}
+			for i, document := range documents {
+				if !strings.Contains(string(document), test.documents[i]) {
+					t.Errorf("document %d does not contain %q:\n%s", i, test.documents[i], document)
+				}
+			}
+		})
+	}
+}
+
+func TestSortManagedObjects(t *testing.T) {
+
+	managedObjects := []ManagedObject{}
+	for _, kind := range []string{"Deployment", "Service", "ConfigMap", "Widget", "Namespace", "CustomResourceDefinition", "Service"} {
+		managedObjects = append(managedObjects, ManagedObject{Struct: &ManagedResourceStruct{Kind: kind, Metadata: MetadataStruct{Name: strings.ToLower(kind) + "-" + strconv.Itoa(len(managedObjects))}}})
+	}
+
+	sortManagedObjects(managedObjects)
+
+	// Kinds outside the apply order come last, and objects of the same kind keep their source order
+	var names []string
+	for _, managedObject := range managedObjects {
+		names = append(names, managedObject.Struct.Metadata.Name)
+	}
+	want := "customresourcedefinition-5 namespace-4 configmap-2 service-1 service-6 deployment-0 widget-3"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("apply order = %s, want %s", got, want)
+	}
+}
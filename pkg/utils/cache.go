@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// DefaultCacheTTL is the time fetched URL contents are reused for if no TTL is configured
+const DefaultCacheTTL = 30 * time.Second
+
+// DefaultMaxRequestsPerHost is the number of requests sent to a single host at the same time if no limit is configured
+const DefaultMaxRequestsPerHost = 4
+
+// staleEntryLifetime is the time an expired entry is kept for conditional requests after it was last used
+const staleEntryLifetime = 24 * time.Hour
+
+// Results of a source cache lookup
+const (
+	cacheResultHit         = "hit"
+	cacheResultMiss        = "miss"
+	cacheResultRevalidated = "revalidated"
+)
+
+// sourceCacheRequests counts URL source reads by whether they were served from the cache
+var sourceCacheRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "managedresource_source_cache_requests_total",
+		Help: "Number of URL source reads by cache result: hit, miss, or revalidated by a conditional request",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(sourceCacheRequests)
+}
+
+// sourceCacheEntry is a verified response of a URL source
+type sourceCacheEntry struct {
+	body         []byte
+	etag         string
+	lastModified string
+	fetchTime    metav1.Time
+	lastUsed     time.Time
+}
+
+// Contents of URL sources shared by the webhook and the reconciler
+var (
+	sourceCache    = make(map[string]*sourceCacheEntry)
+	sourceCacheMux sync.Mutex
+)
+
+// sourceCacheKey identifies the contents of a URL source, which are only shared within a namespace when fetched with credentials
+func sourceCacheKey(sourceStruct SourceStruct, sourceContext SourceContext) string {
+
+	key := sourceStruct.URL + " " + sourceStruct.SignatureURL
+	if sourceStruct.Auth != nil {
+		key += " " + sourceContext.Namespace + "/" + sourceStruct.Auth.SecretName
+	}
+
+	return key
+}
+
+// cachedSource returns the cached entry of the source and whether it was fetched within the TTL
+func cachedSource(key string, ttl time.Duration) (sourceCacheEntry, bool, bool) {
+	sourceCacheMux.Lock()
+	defer sourceCacheMux.Unlock()
+
+	entry, ok := sourceCache[key]
+	if !ok {
+		return sourceCacheEntry{}, false, false
+	}
+	entry.lastUsed = time.Now()
+
+	return *entry, true, time.Since(entry.fetchTime.Time) < ttl
+}
+
+// setConditionalHeaders makes the request conditional on the URL having changed since the cached response
+func setConditionalHeaders(request *http.Request, entry sourceCacheEntry) {
+
+	if entry.etag != "" {
+		request.Header.Set("If-None-Match", entry.etag)
+	}
+	if entry.lastModified != "" {
+		request.Header.Set("If-Modified-Since", entry.lastModified)
+	}
+}
+
+// storeSource caches a verified response and evicts entries which were not used for a while
+func storeSource(key string, entry sourceCacheEntry) {
+	sourceCacheMux.Lock()
+	defer sourceCacheMux.Unlock()
+
+	now := time.Now()
+	for cachedKey, cachedEntry := range sourceCache {
+		if now.Sub(cachedEntry.lastUsed) > staleEntryLifetime {
+			delete(sourceCache, cachedKey)
+		}
+	}
+
+	entry.lastUsed = now
+	sourceCache[key] = &entry
+}
+
+// sourceFetch serializes fetches of the same source so concurrent reads wait for a single request
+type sourceFetch struct {
+	mux     sync.Mutex
+	waiters int
+}
+
+// Fetches of sources which are currently in progress
+var (
+	sourceFetches    = make(map[string]*sourceFetch)
+	sourceFetchesMux sync.Mutex
+)
+
+// lockSource waits until no other fetch of the source is in progress and returns a function unlocking it
+func lockSource(key string) func() {
+
+	sourceFetchesMux.Lock()
+	fetch, ok := sourceFetches[key]
+	if !ok {
+		fetch = &sourceFetch{}
+		sourceFetches[key] = fetch
+	}
+	fetch.waiters++
+	sourceFetchesMux.Unlock()
+
+	fetch.mux.Lock()
+	return func() {
+		fetch.mux.Unlock()
+
+		sourceFetchesMux.Lock()
+		defer sourceFetchesMux.Unlock()
+		if fetch.waiters--; fetch.waiters == 0 {
+			delete(sourceFetches, key)
+		}
+	}
+}
+
+// hostSemaphore limits the requests sent to a host at the same time
+type hostSemaphore struct {
+	slots chan struct{}
+	users int
+}
+
+// Semaphores limiting the requests sent to each host at the same time, which are only kept while requests to the host wait or are in progress
+var (
+	hostSemaphores    = make(map[string]*hostSemaphore)
+	hostSemaphoresMux sync.Mutex
+)
+
+// acquireHost waits until another request may be sent to the host of the URL and returns a function releasing it
+func acquireHost(rawURL string, limit int) func() {
+
+	host := rawURL
+	if parsedURL, err := url.Parse(rawURL); err == nil {
+		host = strings.ToLower(parsedURL.Host)
+	}
+
+	// Replace the semaphore if the limit was changed, requests holding the previous one release it when done
+	hostSemaphoresMux.Lock()
+	semaphore, ok := hostSemaphores[host]
+	if !ok || cap(semaphore.slots) != limit {
+		semaphore = &hostSemaphore{slots: make(chan struct{}, limit)}
+		hostSemaphores[host] = semaphore
+	}
+	semaphore.users++
+	hostSemaphoresMux.Unlock()
+
+	semaphore.slots <- struct{}{}
+	return func() {
+		<-semaphore.slots
+
+		// Evict the semaphore once the host is idle
+		hostSemaphoresMux.Lock()
+		defer hostSemaphoresMux.Unlock()
+		if semaphore.users--; semaphore.users == 0 && hostSemaphores[host] == semaphore {
+			delete(hostSemaphores, host)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAcquireHost(t *testing.T) {
+
+	const limit = 2
+	const requests = 8
+
+	// Count the requests to the host which are in progress at the same time
+	var (
+		inProgress, maxInProgress int
+		mux                       sync.Mutex
+		wg                        sync.WaitGroup
+	)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			release := acquireHost("https://Example.com/object.yaml", limit)
+			defer release()
+
+			mux.Lock()
+			if inProgress++; inProgress > maxInProgress {
+				maxInProgress = inProgress
+			}
+			mux.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mux.Lock()
+			inProgress--
+			mux.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if maxInProgress > limit {
+		t.Errorf("%d requests were in progress at the same time, want at most %d", maxInProgress, limit)
+	}
+
+	// Semaphores of idle hosts are evicted
+	hostSemaphoresMux.Lock()
+	defer hostSemaphoresMux.Unlock()
+	if len(hostSemaphores) != 0 {
+		t.Errorf("%d semaphores are kept for idle hosts, want none", len(hostSemaphores))
+	}
+}
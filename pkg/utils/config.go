@@ -0,0 +1,313 @@
+package utils
+
+import (
+	"crypto/x509"
+	"errors"
+	"mime"
+	"net/url"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultHTTPTimeout is the timeout of a URL source request if no timeout is configured
+const DefaultHTTPTimeout = 10 * time.Second
+
+// HTTPConfig defines how URL sources are fetched
+type HTTPConfig struct {
+
+	// Insecure allows insecure server connections
+	// +optional
+	Insecure bool `json:"insecure,omitempty"`
+
+	// Timeout is the timeout of a single request, defaults to 10s
+	// +optional
+	Timeout metav1.Duration `json:"timeout,omitempty"`
+
+	// CABundle is a PEM encoded certificate bundle to trust in addition to the system certificates
+	// +optional
+	CABundle string `json:"caBundle,omitempty"`
+
+	// Proxy is the URL of the proxy requests are sent through
+	// +optional
+	Proxy string `json:"proxy,omitempty"`
+
+	// NoProxy is a comma separated list of hosts, domains and networks which are not requested through the proxy
+	// +optional
+	NoProxy string `json:"noProxy,omitempty"`
+
+	// AllowedSchemes are the schemes URL sources may use, defaults to https and http
+	// +optional
+	AllowedSchemes []string `json:"allowedSchemes,omitempty"`
+
+	// AllowedHosts are the hosts URL sources may be fetched from, where *. prefixed hosts allow their subdomains
+	// +optional
+	AllowedHosts []string `json:"allowedHosts,omitempty"`
+
+	// AllowedURLPrefixes are the URLs under which URL sources may be fetched from, in addition to the allowed hosts
+	// +optional
+	AllowedURLPrefixes []string `json:"allowedURLPrefixes,omitempty"`
+
+	// AllowedCIDRs are networks URL sources may be fetched from even though they are blocked, such as an internal artifact server or proxy
+	// +optional
+	AllowedCIDRs []string `json:"allowedCIDRs,omitempty"`
+
+	// BlockedCIDRs are networks URL sources may not be fetched from in addition to loopback, link-local and private networks
+	// +optional
+	BlockedCIDRs []string `json:"blockedCIDRs,omitempty"`
+
+	// MaxBodySize is the largest response which is read, defaults to 4Mi
+	// +optional
+	MaxBodySize resource.Quantity `json:"maxBodySize,omitempty"`
+
+	// AllowedContentTypes are the content types URL sources may respond with, defaults to YAML, JSON, plain text and binary types
+	// +optional
+	AllowedContentTypes []string `json:"allowedContentTypes,omitempty"`
+
+	// CacheTTL is the time fetched URL contents are shared between the webhook and reconciliations before being fetched again, defaults to 30s
+	// +optional
+	CacheTTL metav1.Duration `json:"cacheTTL,omitempty"`
+
+	// MaxRequestsPerHost is the number of requests sent to a single host at the same time, defaults to 4
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxRequestsPerHost int `json:"maxRequestsPerHost,omitempty"`
+}
+
+// DeepCopyInto is a custom deep copy method for HTTP config which controller-gen expects
+func (c *HTTPConfig) DeepCopyInto(out *HTTPConfig) {
+
+	copyList := func(list []string) []string {
+		if list == nil {
+			return nil
+		}
+		return append([]string{}, list...)
+	}
+
+	*out = *c
+	(*out).AllowedSchemes = copyList(c.AllowedSchemes)
+	(*out).AllowedHosts = copyList(c.AllowedHosts)
+	(*out).AllowedURLPrefixes = copyList(c.AllowedURLPrefixes)
+	(*out).AllowedCIDRs = copyList(c.AllowedCIDRs)
+	(*out).BlockedCIDRs = copyList(c.BlockedCIDRs)
+	(*out).MaxBodySize = c.MaxBodySize.DeepCopy()
+	(*out).AllowedContentTypes = copyList(c.AllowedContentTypes)
+}
+
+// WithDefaults returns the HTTP configuration with unset fields defaulted
+func (c HTTPConfig) WithDefaults() HTTPConfig {
+
+	if c.Timeout.Duration == 0 {
+		c.Timeout.Duration = DefaultHTTPTimeout
+	}
+	if len(c.AllowedSchemes) == 0 {
+		c.AllowedSchemes = DefaultAllowedSchemes
+	}
+	if c.MaxBodySize.IsZero() {
+		c.MaxBodySize = DefaultMaxBodySize.DeepCopy()
+	}
+	if len(c.AllowedContentTypes) == 0 {
+		c.AllowedContentTypes = DefaultAllowedContentTypes
+	}
+	if c.CacheTTL.Duration == 0 {
+		c.CacheTTL.Duration = DefaultCacheTTL
+	}
+	if c.MaxRequestsPerHost == 0 {
+		c.MaxRequestsPerHost = DefaultMaxRequestsPerHost
+	}
+
+	return c
+}
+
+// Validate ensures the HTTP configuration can be used for requests
+func (c HTTPConfig) Validate() error {
+
+	if c.Timeout.Duration < 0 {
+		return errors.New("http.timeout must not be negative")
+	}
+
+	if c.CABundle != "" {
+		if ok := x509.NewCertPool().AppendCertsFromPEM([]byte(c.CABundle)); !ok {
+			return errors.New("http.caBundle contains no PEM encoded certificates")
+		}
+	}
+
+	if c.Proxy != "" {
+		proxyURL, err := url.Parse(c.Proxy)
+		if err != nil {
+			return errors.New("http.proxy is not a valid URL: " + err.Error())
+		} else if proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return errors.New("http.proxy must be an absolute URL")
+		}
+	}
+
+	for _, scheme := range c.AllowedSchemes {
+		if !containsFold(DefaultAllowedSchemes, scheme) {
+			return errors.New("http.allowedSchemes may only contain https and http")
+		}
+	}
+
+	if c.MaxBodySize.Sign() < 0 {
+		return errors.New("http.maxBodySize must not be negative")
+	}
+
+	for _, contentType := range c.AllowedContentTypes {
+		if _, _, err := mime.ParseMediaType(contentType); err != nil {
+			return errors.New("http.allowedContentTypes contains invalid content type " + contentType + ": " + err.Error())
+		}
+	}
+
+	if c.CacheTTL.Duration < 0 {
+		return errors.New("http.cacheTTL must not be negative")
+	}
+
+	if c.MaxRequestsPerHost < 0 {
+		return errors.New("http.maxRequestsPerHost must not be negative")
+	}
+
+	if _, err := newURLPolicy(c); err != nil {
+		return errors.New("http: " + err.Error())
+	}
+
+	return nil
+}
+
+// FeaturesConfig toggles optional features of the operator, each of which is enabled unless disabled explicitly
+type FeaturesConfig struct {
+
+	// URLSources allows managed resources to read their objects from a URL
+	// +optional
+	URLSources *bool `json:"urlSources,omitempty"`
+
+	// GitSources allows managed resources to read their objects from a git repository
+	// +optional
+	GitSources *bool `json:"gitSources,omitempty"`
+
+	// DriftDetection watches the managed objects and re-applies them as soon as they are changed out of band
+	// +optional
+	DriftDetection *bool `json:"driftDetection,omitempty"`
+}
+
+// DeepCopyInto is a custom deep copy method for features config which controller-gen expects
+func (c *FeaturesConfig) DeepCopyInto(out *FeaturesConfig) {
+
+	copyFeature := func(feature *bool) *bool {
+		if feature == nil {
+			return nil
+		}
+		value := *feature
+		return &value
+	}
+
+	(*out).URLSources = copyFeature(c.URLSources)
+	(*out).GitSources = copyFeature(c.GitSources)
+	(*out).DriftDetection = copyFeature(c.DriftDetection)
+}
+
+// WithDefaults returns the features configuration with every unset feature enabled
+func (c FeaturesConfig) WithDefaults() FeaturesConfig {
+
+	enabled := func(feature *bool) *bool {
+		value := feature == nil || *feature
+		return &value
+	}
+
+	return FeaturesConfig{
+		URLSources:     enabled(c.URLSources),
+		GitSources:     enabled(c.GitSources),
+		DriftDetection: enabled(c.DriftDetection),
+	}
+}
+
+// URLSourcesEnabled checks whether URL sources may be used
+func (c FeaturesConfig) URLSourcesEnabled() bool {
+	return c.URLSources == nil || *c.URLSources
+}
+
+// GitSourcesEnabled checks whether git sources may be used
+func (c FeaturesConfig) GitSourcesEnabled() bool {
+	return c.GitSources == nil || *c.GitSources
+}
+
+// DriftDetectionEnabled checks whether managed objects are watched for out of band changes
+func (c FeaturesConfig) DriftDetectionEnabled() bool {
+	return c.DriftDetection == nil || *c.DriftDetection
+}
+
+// SignaturesConfig defines the signatures sources are verified with
+type SignaturesConfig struct {
+
+	// PublicKey is a GPG or PEM encoded public key which must have signed every source, in which case only URL sources, which come with a detached signature, may be used
+	// +optional
+	PublicKey string `json:"publicKey,omitempty"`
+}
+
+// Validate ensures the signatures configuration can be used to verify sources
+func (c SignaturesConfig) Validate() error {
+
+	if c.PublicKey != "" {
+		if _, err := parsePublicKey([]byte(c.PublicKey)); err != nil {
+			return errors.New("signatures.publicKey is invalid: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Configuration the sources are currently read with
+var (
+	currentHTTPConfig   = HTTPConfig{}.WithDefaults()
+	currentFeatures     = FeaturesConfig{}.WithDefaults()
+	currentPublicKey    *publicKey
+	currentPublicKeyErr error
+	configMux           sync.RWMutex
+)
+
+// Configure replaces the configuration the sources are read with, parsing the public key once
+func Configure(httpConfig HTTPConfig, features FeaturesConfig, signatures SignaturesConfig) {
+	configMux.Lock()
+	defer configMux.Unlock()
+
+	currentHTTPConfig = httpConfig.WithDefaults()
+	currentFeatures = features.WithDefaults()
+
+	// A key which cannot be parsed fails every verification rather than disabling it
+	currentPublicKey, currentPublicKeyErr = nil, nil
+	if signatures.PublicKey != "" {
+		currentPublicKey, currentPublicKeyErr = parsePublicKey([]byte(signatures.PublicKey))
+	}
+}
+
+// CurrentHTTPConfig returns the configuration URL sources are currently fetched with
+func CurrentHTTPConfig() HTTPConfig {
+	configMux.RLock()
+	defer configMux.RUnlock()
+
+	return currentHTTPConfig
+}
+
+// CurrentFeatures returns the currently enabled features
+func CurrentFeatures() FeaturesConfig {
+	configMux.RLock()
+	defer configMux.RUnlock()
+
+	return currentFeatures
+}
+
+// currentSignatureKey returns the public key sources must be signed with, which is nil if signatures are not required
+func currentSignatureKey() (*publicKey, error) {
+	configMux.RLock()
+	defer configMux.RUnlock()
+
+	return currentPublicKey, currentPublicKeyErr
+}
+
+// SignaturesRequired checks whether sources must be signed, in which case only URL sources may be used
+func SignaturesRequired() bool {
+	configMux.RLock()
+	defer configMux.RUnlock()
+
+	return currentPublicKey != nil || currentPublicKeyErr != nil
+}
@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// DefaultMaxBodySize is the largest response of a URL source which is read if no size is configured
+var DefaultMaxBodySize = resource.MustParse("4Mi")
+
+// DefaultAllowedContentTypes are the content types a URL source may respond with if no content types are configured
+var DefaultAllowedContentTypes = []string{
+	"application/yaml",
+	"application/x-yaml",
+	"text/yaml",
+	"text/x-yaml",
+	"application/json",
+	"text/plain",
+	"application/octet-stream",
+}
+
+// Reasons a URL response is rejected for
+const (
+	FetchReasonResponseTooLarge       = "ResponseTooLarge"
+	FetchReasonResponseTruncated      = "ResponseTruncated"
+	FetchReasonUnsupportedContentType = "UnsupportedContentType"
+	FetchReasonNotManifest            = "NotManifest"
+)
+
+// FetchError is returned when the response of a URL is rejected
+type FetchError struct {
+
+	// Reason is a machine readable reason the response was rejected for
+	Reason string
+
+	// URL is the URL the response was received from
+	URL string
+
+	// Message describes why the response was rejected
+	Message string
+}
+
+// Error implements error
+func (e *FetchError) Error() string {
+	return "an error occurred while reading response from " + e.URL + ": " + e.Message
+}
+
+// readLimitedBody reads a response body which may not be larger than the maximum body size
+func readLimitedBody(response *http.Response, url string, maxBodySize int64) ([]byte, error) {
+
+	tooLarge := &FetchError{
+		Reason:  FetchReasonResponseTooLarge,
+		URL:     url,
+		Message: "response is larger than the maximum body size of " + strconv.FormatInt(maxBodySize, 10) + " bytes",
+	}
+
+	// Reject responses which declare their size upfront
+	if response.ContentLength > maxBodySize {
+		return nil, tooLarge
+	}
+
+	// Read one byte past the limit to tell whether the body is larger
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, maxBodySize+1))
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, &FetchError{
+			Reason:  FetchReasonResponseTruncated,
+			URL:     url,
+			Message: "response ended after " + strconv.Itoa(len(body)) + " of " + strconv.FormatInt(response.ContentLength, 10) + " bytes",
+		}
+	} else if err != nil {
+		return nil, errors.New("an error occurred while reading response from " + url + ": " + err.Error())
+	} else if int64(len(body)) > maxBodySize {
+		return nil, tooLarge
+	} else if response.ContentLength >= 0 && int64(len(body)) < response.ContentLength {
+		return nil, &FetchError{
+			Reason:  FetchReasonResponseTruncated,
+			URL:     url,
+			Message: "response ended after " + strconv.Itoa(len(body)) + " of " + strconv.FormatInt(response.ContentLength, 10) + " bytes",
+		}
+	}
+
+	return body, nil
+}
+
+// checkManifestResponse ensures the response has an allowed content type and its body is textual rather than an HTML page or binary data
+func checkManifestResponse(response *http.Response, url string, body []byte, allowedContentTypes []string) error {
+
+	// Responses without a content type are only checked by their contents
+	if contentType := response.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || !containsFold(allowedContentTypes, mediaType) {
+			return &FetchError{
+				Reason:  FetchReasonUnsupportedContentType,
+				URL:     url,
+				Message: "content type " + contentType + " is not one of " + strings.Join(allowedContentTypes, ", "),
+			}
+		}
+	}
+
+	// YAML and JSON manifests are detected as plain text
+	if detectedType := http.DetectContentType(body); !strings.HasPrefix(detectedType, "text/plain") {
+		return &FetchError{
+			Reason:  FetchReasonNotManifest,
+			URL:     url,
+			Message: "response looks like " + detectedType + " rather than a YAML or JSON manifest",
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// errorReader fails every read with its error
+type errorReader struct {
+	err error
+}
+
+// Read implements io.Reader
+func (r errorReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// fetchReason returns the reason of a fetch error, or an empty reason for any other error
+func fetchReason(err error) string {
+
+	fetchError := &FetchError{}
+	if errors.As(err, &fetchError) {
+		return fetchError.Reason
+	}
+
+	return ""
+}
+
+func TestReadLimitedBody(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		body          io.Reader
+		contentLength int64
+		reason        string
+		wantErr       bool
+	}{
+		{name: "within limit", body: strings.NewReader("0123456789"), contentLength: 10},
+		{name: "within limit of unknown length", body: strings.NewReader("0123456789"), contentLength: -1},
+		{name: "empty", body: strings.NewReader(""), contentLength: 0},
+		{name: "declared too large", body: strings.NewReader("0123456789"), contentLength: 11, reason: FetchReasonResponseTooLarge, wantErr: true},
+		{name: "too large of unknown length", body: strings.NewReader("0123456789a"), contentLength: -1, reason: FetchReasonResponseTooLarge, wantErr: true},
+		{name: "larger than declared", body: strings.NewReader("0123456789a"), contentLength: 5, reason: FetchReasonResponseTooLarge, wantErr: true},
+		{name: "shorter than declared", body: strings.NewReader("01234"), contentLength: 10, reason: FetchReasonResponseTruncated, wantErr: true},
+		{name: "unexpected EOF", body: io.MultiReader(strings.NewReader("01234"), errorReader{io.ErrUnexpectedEOF}), contentLength: -1, reason: FetchReasonResponseTruncated, wantErr: true},
+		{name: "read error", body: errorReader{errors.New("connection reset")}, contentLength: -1, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			response := &http.Response{Body: ioutil.NopCloser(test.body), ContentLength: test.contentLength}
+			body, err := readLimitedBody(response, "https://example.com/object.yaml", 10)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("readLimitedBody() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if reason := fetchReason(err); reason != test.reason {
+				t.Errorf("reason = %q, want %q", reason, test.reason)
+			}
+			if err == nil && test.contentLength >= 0 && int64(len(body)) != test.contentLength {
+				t.Errorf("read %d bytes, want %d", len(body), test.contentLength)
+			}
+		})
+	}
+}
+
+func TestCheckManifestResponse(t *testing.T) {
+
+	manifest := []byte("apiVersion: v1\nkind: ConfigMap\n")
+	tests := map[string]struct {
+		contentType string
+		body        []byte
+		reason      string
+	}{
+		"yaml":                    {contentType: "application/yaml", body: manifest},
+		"content type parameters": {contentType: "text/plain; charset=utf-8", body: manifest},
+		"content type case":       {contentType: "Application/YAML", body: manifest},
+		"no content type":         {body: manifest},
+		"html":                    {contentType: "text/html", body: manifest, reason: FetchReasonUnsupportedContentType},
+		"invalid content type":    {contentType: "/", body: manifest, reason: FetchReasonUnsupportedContentType},
+		"html page":               {contentType: "text/plain", body: []byte("<!DOCTYPE html><html></html>"), reason: FetchReasonNotManifest},
+		"binary":                  {body: []byte{0x1f, 0x8b, 0x08, 0x00}, reason: FetchReasonNotManifest},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+
+			response := &http.Response{Header: http.Header{}}
+			if test.contentType != "" {
+				response.Header.Set("Content-Type", test.contentType)
+			}
+
+			err := checkManifestResponse(response, "https://example.com/object.yaml", test.body, DefaultAllowedContentTypes)
+			if reason := fetchReason(err); reason != test.reason || (err != nil) != (test.reason != "") {
+				t.Errorf("checkManifestResponse() error = %v, want reason %q", err, test.reason)
+			}
+		})
+	}
+}
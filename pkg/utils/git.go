@@ -0,0 +1,285 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitclient "github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// Keys of a git credentials secret
+const (
+	GitUsernameKey = "username"
+	GitPasswordKey = "password"
+)
+
+// GitSource is a reference to a manifest file within a git repository
+type GitSource struct {
+
+	// URL is the URL of the repository
+	// +kubebuilder:validation:MinLength=1
+	URL string `json:"url"`
+
+	// Ref is the branch, tag or commit to read the manifest from, the default branch is used if not set
+	// +optional
+	Ref string `json:"ref,omitempty"`
+
+	// Path is the path of the manifest file within the repository
+	// +kubebuilder:validation:MinLength=1
+	Path string `json:"path"`
+
+	// SecretName is the name of a secret within the managed resource namespace holding the username and password of the repository, which must be labeled managedresources.paas.il/credentials=true
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// gitProtocols are the protocols git repositories may be cloned with, local repositories are never read
+var gitProtocols = []string{"https", "http"}
+
+// Limits of a clone, which are mostly reached by commits which are not the head of a branch or tag since they require a full clone of the repository
+var (
+	gitCloneTimeout       = 2 * time.Minute
+	gitCloneMaxSize int64 = 256 << 20
+)
+
+// limitedStorage is an in-memory repository storage which refuses objects once their total size exceeds the limit
+type limitedStorage struct {
+	*memory.Storage
+	size  int64
+	limit int64
+}
+
+// SetEncodedObject stores an object unless the limit is exceeded
+func (s *limitedStorage) SetEncodedObject(obj plumbing.EncodedObject) (plumbing.Hash, error) {
+
+	s.size += obj.Size()
+	if s.size > s.limit {
+		return plumbing.ZeroHash, errors.New("repository is larger than " + strconv.FormatInt(s.limit, 10) + " bytes")
+	}
+
+	return s.Storage.SetEncodedObject(obj)
+}
+
+// gitCacheEntry is a manifest file read from a resolved commit
+type gitCacheEntry struct {
+	contents []byte
+	revision string
+	lastUsed time.Time
+}
+
+// Manifest files of git sources by their resolved ref, which are immutable and therefore never refreshed
+var (
+	gitCache    = make(map[string]*gitCacheEntry)
+	gitCacheMux sync.Mutex
+)
+
+// gitCacheKey identifies a manifest file of a resolved ref, which is only shared within a namespace when read with credentials
+func gitCacheKey(gitSource GitSource, hash plumbing.Hash, sourceContext SourceContext) string {
+
+	key := gitSource.URL + " " + hash.String() + " " + gitSource.Path
+	if gitSource.SecretName != "" {
+		key += " " + sourceContext.Namespace + "/" + gitSource.SecretName
+	}
+
+	return key
+}
+
+// cachedGitFile returns the cached manifest file of the key if present
+func cachedGitFile(key string) (gitCacheEntry, bool) {
+	gitCacheMux.Lock()
+	defer gitCacheMux.Unlock()
+
+	entry, ok := gitCache[key]
+	if !ok {
+		return gitCacheEntry{}, false
+	}
+	entry.lastUsed = time.Now()
+
+	return *entry, true
+}
+
+// storeGitFile caches a manifest file and evicts files which were not used for a while
+func storeGitFile(key string, entry gitCacheEntry) {
+	gitCacheMux.Lock()
+	defer gitCacheMux.Unlock()
+
+	now := time.Now()
+	for cachedKey, cachedEntry := range gitCache {
+		if now.Sub(cachedEntry.lastUsed) > staleEntryLifetime {
+			delete(gitCache, cachedKey)
+		}
+	}
+
+	entry.lastUsed = now
+	gitCache[key] = &entry
+}
+
+func init() {
+
+	// Clone repositories with the HTTP configuration and URL policy of URL sources rather than the default client
+	client := githttp.NewClient(&http.Client{Transport: configuredTransport{}})
+	gitclient.InstallProtocol("https", client)
+	gitclient.InstallProtocol("http", client)
+}
+
+func getManagedResourceBytesByGit(sourceStruct SourceStruct, sourceContext SourceContext) ([]byte, SourceStatus, error) {
+
+	if !CurrentFeatures().GitSourcesEnabled() {
+		return nil, SourceStatus{}, errors.New("git sources are disabled by the operator configuration")
+	}
+
+	gitSource := sourceStruct.Git
+
+	// Only clone remote repositories
+	endpoint, err := transport.NewEndpoint(gitSource.URL)
+	if err != nil {
+		return nil, SourceStatus{}, errors.New("an error occurred while parsing " + gitSource.URL + ": " + err.Error())
+	}
+	if !containsFold(gitProtocols, endpoint.Protocol) {
+		return nil, SourceStatus{}, errors.New(gitSource.URL + ": protocol " + endpoint.Protocol + " is not allowed, must be one of " + strings.Join(gitProtocols, ", "))
+	}
+
+	// Read repository credentials if present
+	var auth transport.AuthMethod
+	if gitSource.SecretName != "" {
+		secret, err := getCredentialsSecret(sourceContext, gitSource.SecretName)
+		if err != nil {
+			return nil, SourceStatus{}, errors.New("an error occurred while reading git credentials: " + err.Error())
+		}
+
+		auth = &githttp.BasicAuth{
+			Username: string(secret.Data[GitUsernameKey]),
+			Password: string(secret.Data[GitPasswordKey]),
+		}
+	}
+
+	// Resolve ref to a branch or tag of the remote, or take it as a commit
+	refName, hash, err := resolveGitRef(gitSource.URL, gitSource.Ref, auth)
+	if err != nil {
+		return nil, SourceStatus{}, errors.New("an error occurred while resolving " + gitSource.URL + " ref " + gitSource.Ref + ": " + err.Error())
+	}
+
+	// Reuse the file read from the same commit, waiting for clones of it in progress
+	key := gitCacheKey(*gitSource, hash, sourceContext)
+	if entry, ok := cachedGitFile(key); ok {
+		return entry.contents, SourceStatus{Revision: entry.revision}, nil
+	}
+	unlock := lockSource(key)
+	defer unlock()
+	if entry, ok := cachedGitFile(key); ok {
+		return entry.contents, SourceStatus{Revision: entry.revision}, nil
+	}
+
+	// Clone only the resolved branch or tag into memory, commits which are not the head of one require a full clone, bounded in time and size
+	cloneOptions := &git.CloneOptions{
+		URL:  gitSource.URL,
+		Auth: auth,
+		Tags: git.AllTags,
+	}
+	if refName != "" {
+		cloneOptions.ReferenceName = refName
+		cloneOptions.SingleBranch = true
+		cloneOptions.Depth = 1
+		cloneOptions.Tags = git.NoTags
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), gitCloneTimeout)
+	defer cancel()
+	repository, err := git.CloneContext(ctx, &limitedStorage{Storage: memory.NewStorage(), limit: gitCloneMaxSize}, nil, cloneOptions)
+	if err != nil {
+		return nil, SourceStatus{}, errors.New("an error occurred while cloning " + gitSource.URL + ": " + err.Error())
+	}
+
+	// Get the commit, peeling annotated tags
+	var commit *object.Commit
+	if tagObject, tagErr := repository.TagObject(hash); tagErr == nil {
+		commit, err = tagObject.Commit()
+	} else {
+		commit, err = repository.CommitObject(hash)
+	}
+	if err != nil {
+		return nil, SourceStatus{}, errors.New("an error occurred while resolving " + gitSource.URL + " ref " + gitSource.Ref + ": " + err.Error())
+	}
+
+	// Read manifest file from the commit
+	file, err := commit.File(gitSource.Path)
+	if err != nil {
+		return nil, SourceStatus{}, errors.New("an error occurred while reading " + gitSource.Path + " from " + gitSource.URL + ": " + err.Error())
+	}
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, SourceStatus{}, errors.New("an error occurred while reading " + gitSource.Path + " from " + gitSource.URL + ": " + err.Error())
+	}
+
+	storeGitFile(key, gitCacheEntry{contents: []byte(contents), revision: commit.Hash.String()})
+
+	return []byte(contents), SourceStatus{Revision: commit.Hash.String()}, nil
+}
+
+// resolveGitRef lists the references of the remote to resolve a branch or tag to its name and hash, or returns the hash of a commit without a name
+func resolveGitRef(url string, ref string, auth transport.AuthMethod) (plumbing.ReferenceName, plumbing.Hash, error) {
+
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{url},
+	})
+	references, err := remote.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		return "", plumbing.ZeroHash, err
+	}
+
+	hashes := make(map[plumbing.ReferenceName]plumbing.Hash, len(references))
+	var head *plumbing.Reference
+	for _, reference := range references {
+		if reference.Name() == plumbing.HEAD {
+			head = reference
+		} else if reference.Type() == plumbing.HashReference {
+			hashes[reference.Name()] = reference.Hash()
+		}
+	}
+
+	// Use the default branch if no ref is specified
+	if ref == "" {
+		if head == nil {
+			return "", plumbing.ZeroHash, errors.New("remote has no default branch")
+		}
+		if head.Type() == plumbing.SymbolicReference {
+			if hash, ok := hashes[head.Target()]; ok {
+				return head.Target(), hash, nil
+			}
+		}
+
+		// Remotes which do not advertise the target of HEAD, find a branch at the same commit
+		for name, hash := range hashes {
+			if name.IsBranch() && hash == head.Hash() {
+				return name, hash, nil
+			}
+		}
+		return "", plumbing.ZeroHash, errors.New("remote has no default branch")
+	}
+
+	// Look for a branch, then for a tag
+	for _, name := range []plumbing.ReferenceName{plumbing.NewBranchReferenceName(ref), plumbing.NewTagReferenceName(ref)} {
+		if hash, ok := hashes[name]; ok {
+			return name, hash, nil
+		}
+	}
+
+	// Look for a commit
+	if plumbing.IsHash(ref) {
+		return "", plumbing.NewHash(ref), nil
+	}
+
+	return "", plumbing.ZeroHash, errors.New("no such branch, tag or commit")
+}
@@ -0,0 +1,210 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// testRepository is a bare repository along with a working repository pushing to it
+type testRepository struct {
+	t        *testing.T
+	bareURL  string
+	worktree *git.Worktree
+	work     *git.Repository
+	workDir  string
+	dir      string
+}
+
+// newTestRepository creates a bare repository holding a single commit of the file on the master branch, which is removed by calling remove
+func newTestRepository(t *testing.T, path string, contents string) *testRepository {
+
+	dir, err := ioutil.TempDir("", "git-source")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Push commits of a working repository to an empty bare one
+	bareDir := filepath.Join(dir, "bare.git")
+	if _, err := git.PlainInit(bareDir, true); err != nil {
+		t.Fatal(err)
+	}
+
+	repository := &testRepository{t: t, bareURL: "file://" + bareDir, workDir: filepath.Join(dir, "work"), dir: dir}
+	if repository.work, err = git.PlainInit(repository.workDir, false); err != nil {
+		t.Fatal(err)
+	}
+	if repository.worktree, err = repository.work.Worktree(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.work.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{repository.bareURL}}); err != nil {
+		t.Fatal(err)
+	}
+	repository.commit(path, contents)
+
+	return repository
+}
+
+// remove deletes the repositories
+func (r *testRepository) remove() {
+	os.RemoveAll(r.dir)
+}
+
+// commit writes the file and commits it, returning the commit hash
+func (r *testRepository) commit(path string, contents string) plumbing.Hash {
+
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(r.workDir, path)), 0755); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(r.workDir, path), []byte(contents), 0644); err != nil {
+		r.t.Fatal(err)
+	}
+	if _, err := r.worktree.Add(path); err != nil {
+		r.t.Fatal(err)
+	}
+	hash, err := r.worktree.Commit("update "+path, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		r.t.Fatal(err)
+	}
+
+	return hash
+}
+
+// push pushes all branches and tags of the working repository to the bare one
+func (r *testRepository) push() {
+
+	err := r.work.Push(&git.PushOptions{RefSpecs: []config.RefSpec{"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"}})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		r.t.Fatal(err)
+	}
+}
+
+// allowFileProtocol lets git sources read local repositories until the returned function is called, which are served by git-upload-pack as it supports shallow clones
+func allowFileProtocol() func() {
+
+	previous := gitProtocols
+	gitProtocols = append([]string{"file"}, previous...)
+
+	return func() { gitProtocols = previous }
+}
+
+func TestGetManagedResourceBytesByGit(t *testing.T) {
+
+	if _, err := exec.LookPath("git-upload-pack"); err != nil {
+		t.Skip("git-upload-pack is required to serve local repositories")
+	}
+	defer allowFileProtocol()()
+
+	repository := newTestRepository(t, "manifests/object.yaml", "first")
+	defer repository.remove()
+	first, err := repository.work.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tag the first commit, both lightweight and annotated
+	if _, err := repository.work.CreateTag("v1", first.Hash(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.work.CreateTag("v1-annotated", first.Hash(), &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		Message: "v1",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Branch off the first commit, then move master on
+	if err := repository.work.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("stable"), first.Hash())); err != nil {
+		t.Fatal(err)
+	}
+	second := repository.commit("manifests/object.yaml", "second")
+	repository.push()
+
+	tests := []struct {
+		name     string
+		ref      string
+		path     string
+		contents string
+		revision plumbing.Hash
+		wantErr  bool
+	}{
+		{name: "default branch", ref: "", path: "manifests/object.yaml", contents: "second", revision: second},
+		{name: "branch", ref: "stable", path: "manifests/object.yaml", contents: "first", revision: first.Hash()},
+		{name: "tag", ref: "v1", path: "manifests/object.yaml", contents: "first", revision: first.Hash()},
+		{name: "annotated tag", ref: "v1-annotated", path: "manifests/object.yaml", contents: "first", revision: first.Hash()},
+		{name: "commit", ref: first.Hash().String(), path: "manifests/object.yaml", contents: "first", revision: first.Hash()},
+		{name: "missing ref", ref: "missing", path: "manifests/object.yaml", wantErr: true},
+		{name: "missing path", ref: "stable", path: "manifests/missing.yaml", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			sourceStruct := SourceStruct{Git: &GitSource{URL: repository.bareURL, Ref: test.ref, Path: test.path}}
+			contents, status, err := getManagedResourceBytesByGit(sourceStruct, SourceContext{Namespace: "default"})
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", contents)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(contents) != test.contents {
+				t.Errorf("contents = %q, want %q", contents, test.contents)
+			}
+			if status.Revision != test.revision.String() {
+				t.Errorf("revision = %s, want %s", status.Revision, test.revision)
+			}
+		})
+	}
+
+	// The default branch is resolved again once it moves on
+	third := repository.commit("manifests/object.yaml", "third")
+	repository.push()
+
+	contents, status, err := getManagedResourceBytesByGit(SourceStruct{Git: &GitSource{URL: repository.bareURL, Path: "manifests/object.yaml"}}, SourceContext{Namespace: "default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "third" || status.Revision != third.String() {
+		t.Errorf("got %q at %s, want %q at %s", contents, status.Revision, "third", third)
+	}
+
+	// Clones larger than the limit are refused
+	fourth := repository.commit("manifests/object.yaml", "fourth")
+	repository.push()
+	previousMaxSize := gitCloneMaxSize
+	gitCloneMaxSize = 1
+	defer func() { gitCloneMaxSize = previousMaxSize }()
+
+	_, _, err = getManagedResourceBytesByGit(SourceStruct{Git: &GitSource{URL: repository.bareURL, Ref: fourth.String(), Path: "manifests/object.yaml"}}, SourceContext{Namespace: "default"})
+	if err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("expected the clone to exceed the size limit, got %v", err)
+	}
+}
+
+func TestGetManagedResourceBytesByGitProtocols(t *testing.T) {
+
+	for _, url := range []string{"file:///var/lib/repository.git", "/var/lib/repository.git", "ssh://git@example.com/repository.git", "git@example.com:repository.git"} {
+		t.Run(url, func(t *testing.T) {
+			sourceStruct := SourceStruct{Git: &GitSource{URL: url, Path: "object.yaml"}}
+			if _, _, err := getManagedResourceBytesByGit(sourceStruct, SourceContext{Namespace: "default"}); err == nil {
+				t.Error("expected the protocol to be refused")
+			}
+		})
+	}
+}
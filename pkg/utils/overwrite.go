@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/jeremywohl/flatten"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+// OverwriteStrategy is the way overwrite fields are merged into the managed object
+// +kubebuilder:validation:Enum=Merge;JSONMerge;StrategicMerge
+type OverwriteStrategy string
+
+// Valid overwrite strategies
+const (
+	OverwriteStrategyMerge          OverwriteStrategy = "Merge"
+	OverwriteStrategyJSONMerge      OverwriteStrategy = "JSONMerge"
+	OverwriteStrategyStrategicMerge OverwriteStrategy = "StrategicMerge"
+)
+
+// jsonMergeOverwrite merges the overwrite into the resource as an RFC 7386 JSON merge patch, where null fields are deleted
+func jsonMergeOverwrite(managedResourceBytes []byte, overwrite runtime.RawExtension) ([]byte, error) {
+
+	managedResourceJSON, err := yaml.YAMLToJSON(managedResourceBytes)
+	if err != nil {
+		return nil, errors.New("an error occurred while trying to unmarshal resource: " + err.Error())
+	}
+
+	managedResourceJSON, err = jsonpatch.MergePatch(managedResourceJSON, overwrite.Raw)
+	if err != nil {
+		return nil, errors.New("an error occurred while trying to overwrite parameters: " + err.Error())
+	}
+
+	return marshalOverwrittenResource(managedResourceJSON)
+}
+
+// strategicMergeOverwrite merges the overwrite into the resource as a strategic merge patch using the patch merge keys of its kind,
+// falling back to a JSON merge patch for kinds which are not built into Kubernetes
+func strategicMergeOverwrite(managedResourceBytes []byte, overwrite runtime.RawExtension) ([]byte, error) {
+
+	managedResourceJSON, err := yaml.YAMLToJSON(managedResourceBytes)
+	if err != nil {
+		return nil, errors.New("an error occurred while trying to unmarshal resource: " + err.Error())
+	}
+
+	// Find the typed struct of the kind, which holds its patch merge keys
+	managedObject := &unstructured.Unstructured{}
+	if err := managedObject.UnmarshalJSON(managedResourceJSON); err != nil {
+		return nil, errors.New("an error occurred while trying to unmarshal resource: " + err.Error())
+	}
+	dataStruct, err := scheme.Scheme.New(managedObject.GroupVersionKind())
+	if err != nil {
+		return jsonMergeOverwrite(managedResourceBytes, overwrite)
+	}
+
+	managedResourceJSON, err = strategicpatch.StrategicMergePatch(managedResourceJSON, overwrite.Raw, dataStruct)
+	if err != nil {
+		return nil, errors.New("an error occurred while trying to overwrite parameters: " + err.Error())
+	}
+
+	return marshalOverwrittenResource(managedResourceJSON)
+}
+
+// marshalOverwrittenResource converts the overwritten JSON resource back to YAML bytes
+func marshalOverwrittenResource(managedResourceJSON []byte) ([]byte, error) {
+
+	managedResourceBytesOverwrite, err := yaml.JSONToYAML(managedResourceJSON)
+	if err != nil {
+		return nil, errors.New("an error occurred while trying to marshal overwritten resource: " + err.Error())
+	}
+
+	return managedResourceBytesOverwrite, nil
+}
+
+// OverwriteFields returns the paths of the fields the overwrite sets or deletes, sorted
+func OverwriteFields(overwrite runtime.RawExtension) ([]string, error) {
+
+	if overwrite.Raw == nil {
+		return nil, nil
+	}
+
+	var overwriteMap map[string]interface{}
+	if err := json.Unmarshal(overwrite.Raw, &overwriteMap); err != nil {
+		return nil, errors.New("an error occurred while trying to unmarshal overwrite: " + err.Error())
+	}
+
+	flatOverwrite, err := flatten.Flatten(overwriteMap, "", flatten.DotStyle)
+	if err != nil {
+		return nil, errors.New("an error occurred while trying to read overwrite fields: " + err.Error())
+	}
+
+	fields := make([]string, 0, len(flatOverwrite))
+	for field := range flatOverwrite {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	return fields, nil
+}
@@ -0,0 +1,256 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// Valid JSON patch operations
+const (
+	PatchOpAdd     = "add"
+	PatchOpRemove  = "remove"
+	PatchOpReplace = "replace"
+	PatchOpMove    = "move"
+	PatchOpCopy    = "copy"
+	PatchOpTest    = "test"
+)
+
+// PatchOperation is a single RFC 6902 JSON patch operation
+type PatchOperation struct {
+
+	// Op is the operation to perform
+	// +kubebuilder:validation:Enum=add;remove;replace;move;copy;test
+	Op string `json:"op"`
+
+	// Path is the JSON pointer to the field the operation is performed on
+	Path string `json:"path"`
+
+	// From is the JSON pointer to the field which is moved or copied
+	// +optional
+	From string `json:"from,omitempty"`
+
+	// Value is the value which is added, replaced with or tested against
+	// +optional
+	Value *runtime.RawExtension `json:"value,omitempty"`
+
+	// Target selects the objects of a bundle the operation is applied to, it is applied to every object if not set
+	// +optional
+	Target *PatchTarget `json:"target,omitempty"`
+}
+
+// PatchTarget selects objects of a bundle by their kind and name
+type PatchTarget struct {
+
+	// Kind is the kind of the objects, any kind is selected if not set
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// Name is the name of the objects, any name is selected if not set
+	// +optional
+	Name string `json:"name,omitempty"`
+}
+
+// DeepCopyInto is a custom deep copy method for patch operation which controller-gen expects
+func (p *PatchOperation) DeepCopyInto(out *PatchOperation) {
+	*out = *p
+	if p.Value != nil {
+		out.Value = p.Value.DeepCopy()
+	}
+	if p.Target != nil {
+		target := *p.Target
+		out.Target = &target
+	}
+}
+
+// selects checks whether the operation is applied to the object
+func (p PatchOperation) selects(managedObject ManagedObject) bool {
+
+	if p.Target == nil {
+		return true
+	}
+
+	return (p.Target.Kind == "" || p.Target.Kind == managedObject.Struct.Kind) &&
+		(p.Target.Name == "" || p.Target.Name == managedObject.Struct.Metadata.Name)
+}
+
+// validate ensures the operation has the fields its type requires
+func (p PatchOperation) validate() error {
+
+	switch p.Op {
+	case PatchOpAdd, PatchOpReplace, PatchOpTest:
+		if p.Value == nil || p.Value.Raw == nil {
+			return errors.New(p.Op + " operation requires a value")
+		}
+	case PatchOpMove, PatchOpCopy:
+		if p.From == "" {
+			return errors.New(p.Op + " operation requires from")
+		}
+	case PatchOpRemove:
+	default:
+		return errors.New("unknown operation " + p.Op)
+	}
+
+	return nil
+}
+
+// ProcessPatches applies the JSON patch operations to the resource in order
+func ProcessPatches(managedResourceBytes []byte, patches []PatchOperation) ([]byte, error) {
+	return processPatches(managedResourceBytes, patches, nil)
+}
+
+// processPatches applies the JSON patch operations which the resource is selected by, or all of them if it is nil, keeping their indexes in errors
+func processPatches(managedResourceBytes []byte, patches []PatchOperation, managedObject *ManagedObject) ([]byte, error) {
+
+	// Do not patch if there is nothing to patch with
+	if len(patches) == 0 {
+		return managedResourceBytes, nil
+	}
+
+	managedResourceJSON, err := yaml.YAMLToJSON(managedResourceBytes)
+	if err != nil {
+		return nil, errors.New("an error occurred while trying to unmarshal resource: " + err.Error())
+	}
+
+	// Apply operations one at a time so a failure is reported with its index
+	for index, patch := range patches {
+		if managedObject != nil && !patch.selects(*managedObject) {
+			continue
+		}
+
+		patchError := func(err error) error {
+			return fmt.Errorf("patch operation %d (%s %s) failed: %s", index, patch.Op, patch.Path, err)
+		}
+
+		if err := patch.validate(); err != nil {
+			return nil, patchError(err)
+		}
+
+		// Replaced fields must exist, which the patch library does not enforce
+		if patch.Op == PatchOpReplace {
+			exists, err := pointerExists(managedResourceJSON, patch.Path)
+			if err != nil {
+				return nil, patchError(err)
+			} else if !exists {
+				return nil, patchError(errors.New("path does not exist"))
+			}
+		}
+
+		// The target is not part of the JSON patch
+		operation := patch
+		operation.Target = nil
+		patchJSON, err := json.Marshal([]PatchOperation{operation})
+		if err != nil {
+			return nil, patchError(err)
+		}
+		jsonPatch, err := jsonpatch.DecodePatch(patchJSON)
+		if err != nil {
+			return nil, patchError(err)
+		}
+
+		if managedResourceJSON, err = jsonPatch.Apply(managedResourceJSON); err != nil {
+			return nil, patchError(err)
+		}
+	}
+
+	// Marshal final resource as bytes
+	managedResourceBytesPatched, err := yaml.JSONToYAML(managedResourceJSON)
+	if err != nil {
+		return nil, errors.New("an error occurred while trying to marshal patched resource: " + err.Error())
+	}
+
+	return managedResourceBytesPatched, nil
+}
+
+// pointerExists checks whether the JSON pointer refers to a value within the JSON document
+func pointerExists(document []byte, pointer string) (bool, error) {
+
+	var value interface{}
+	if err := json.Unmarshal(document, &value); err != nil {
+		return false, err
+	}
+
+	if pointer == "" {
+		return true, nil
+	} else if !strings.HasPrefix(pointer, "/") {
+		return false, errors.New("path must start with /")
+	}
+
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+
+		switch container := value.(type) {
+		case map[string]interface{}:
+			child, ok := container[token]
+			if !ok {
+				return false, nil
+			}
+			value = child
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(container) {
+				return false, nil
+			}
+			value = container[index]
+		default:
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// PatchObjects applies the JSON patch operations to each of the objects they select and returns them in the order they should be applied
+func PatchObjects(managedObjects []ManagedObject, patches []PatchOperation) ([]ManagedObject, error) {
+
+	// Do not patch if there is nothing to patch with
+	if len(patches) == 0 {
+		return managedObjects, nil
+	}
+
+	// A targeted operation which selects no object is most likely a mistake, which is reported rather than ignored
+	for index, patch := range patches {
+		selected := false
+		for _, managedObject := range managedObjects {
+			selected = selected || patch.selects(managedObject)
+		}
+		if !selected {
+			return nil, fmt.Errorf("patch operation %d (%s %s) failed: target selects no object", index, patch.Op, patch.Path)
+		}
+	}
+
+	patchedObjects := make([]ManagedObject, 0, len(managedObjects))
+	for _, managedObject := range managedObjects {
+
+		// Apply the operations which select the object, keeping the object unchanged if there are none
+		selected := false
+		for _, patch := range patches {
+			selected = selected || patch.selects(managedObject)
+		}
+		if !selected {
+			patchedObjects = append(patchedObjects, managedObject)
+			continue
+		}
+
+		managedObjectBytes, err := processPatches(managedObject.Bytes, patches, &managedObject)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", managedObject, err)
+		}
+
+		patchedObject, err := ProcessObject(managedObjectBytes)
+		if err != nil {
+			return nil, err
+		}
+		patchedObjects = append(patchedObjects, patchedObject)
+	}
+
+	sortManagedObjects(patchedObjects)
+
+	return patchedObjects, nil
+}
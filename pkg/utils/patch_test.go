@@ -0,0 +1,226 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// rawValue returns a patch value of the JSON document
+func rawValue(value string) *runtime.RawExtension {
+	return &runtime.RawExtension{Raw: []byte(value)}
+}
+
+func TestPatchObjects(t *testing.T) {
+
+	deployment := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\nspec:\n  replicas: 1\n"
+	service := "apiVersion: v1\nkind: Service\nmetadata:\n  name: app\nspec:\n  type: ClusterIP\n"
+
+	managedObjects := make([]ManagedObject, 0, 2)
+	for _, document := range []string{deployment, service} {
+		managedObject, err := ProcessObject([]byte(document))
+		if err != nil {
+			t.Fatal(err)
+		}
+		managedObjects = append(managedObjects, managedObject)
+	}
+
+	tests := []struct {
+		name     string
+		patches  []PatchOperation
+		contains map[string]string
+		wantErr  string
+	}{
+		{
+			name:    "untargeted replace of a missing path",
+			patches: []PatchOperation{{Op: PatchOpReplace, Path: "/spec/replicas", Value: rawValue("3")}},
+			wantErr: "patch operation 0",
+		},
+		{
+			name: "targeted by kind",
+			patches: []PatchOperation{
+				{Op: PatchOpReplace, Path: "/spec/replicas", Value: rawValue("3"), Target: &PatchTarget{Kind: "Deployment"}},
+				{Op: PatchOpTest, Path: "/spec/type", Value: rawValue(`"ClusterIP"`), Target: &PatchTarget{Kind: "Service", Name: "app"}},
+			},
+			contains: map[string]string{"Deployment": "replicas: 3", "Service": "type: ClusterIP"},
+		},
+		{
+			name: "targeted by name",
+			patches: []PatchOperation{
+				{Op: PatchOpAdd, Path: "/metadata/labels", Value: rawValue(`{"team":"payments"}`), Target: &PatchTarget{Name: "app"}},
+			},
+			contains: map[string]string{"Deployment": "team: payments", "Service": "team: payments"},
+		},
+		{
+			name: "failure keeps the index of the operation",
+			patches: []PatchOperation{
+				{Op: PatchOpReplace, Path: "/spec/replicas", Value: rawValue("3"), Target: &PatchTarget{Kind: "Deployment"}},
+				{Op: PatchOpTest, Path: "/spec/type", Value: rawValue(`"NodePort"`), Target: &PatchTarget{Kind: "Service"}},
+			},
+			wantErr: "patch operation 1",
+		},
+		{
+			name: "target selects no object",
+			patches: []PatchOperation{
+				{Op: PatchOpRemove, Path: "/spec/replicas", Target: &PatchTarget{Kind: "Deployment", Name: "other"}},
+			},
+			wantErr: "selects no object",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			patchedObjects, err := PatchObjects(managedObjects, test.patches)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, patchedObject := range patchedObjects {
+				if want, ok := test.contains[patchedObject.Struct.Kind]; ok && !strings.Contains(string(patchedObject.Bytes), want) {
+					t.Errorf("%s does not contain %q:\n%s", patchedObject, want, patchedObject.Bytes)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessPatches(t *testing.T) {
+
+	deployment := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app\n  labels:\n    team: payments\nspec:\n  replicas: 1\n  template:\n    spec:\n      containers:\n      - name: app\n        image: app:1.0\n"
+
+	tests := []struct {
+		name     string
+		patches  []PatchOperation
+		contains []string
+		excludes []string
+		wantErr  string
+	}{
+		{name: "no patches", contains: []string{"replicas: 1"}},
+		{
+			name:     "add",
+			patches:  []PatchOperation{{Op: PatchOpAdd, Path: "/metadata/annotations", Value: rawValue(`{"owner":"platform"}`)}},
+			contains: []string{"owner: platform", "replicas: 1"},
+		},
+		{
+			name:     "add to array",
+			patches:  []PatchOperation{{Op: PatchOpAdd, Path: "/spec/template/spec/containers/-", Value: rawValue(`{"name":"sidecar","image":"sidecar:1.0"}`)}},
+			contains: []string{"name: sidecar", "name: app"},
+		},
+		{
+			name:     "remove",
+			patches:  []PatchOperation{{Op: PatchOpRemove, Path: "/metadata/labels/team"}},
+			excludes: []string{"team: payments"},
+		},
+		{
+			name:     "replace",
+			patches:  []PatchOperation{{Op: PatchOpReplace, Path: "/spec/replicas", Value: rawValue("3")}},
+			contains: []string{"replicas: 3"},
+		},
+		{
+			name:     "replace escaped path",
+			patches:  []PatchOperation{{Op: PatchOpAdd, Path: "/metadata/labels/app.kubernetes.io~1name", Value: rawValue(`"app"`)}, {Op: PatchOpReplace, Path: "/metadata/labels/app.kubernetes.io~1name", Value: rawValue(`"other"`)}},
+			contains: []string{"app.kubernetes.io/name: other"},
+		},
+		{
+			name:     "test and replace",
+			patches:  []PatchOperation{{Op: PatchOpTest, Path: "/spec/replicas", Value: rawValue("1")}, {Op: PatchOpReplace, Path: "/spec/replicas", Value: rawValue("2")}},
+			contains: []string{"replicas: 2"},
+		},
+		{
+			name:     "move",
+			patches:  []PatchOperation{{Op: PatchOpMove, From: "/metadata/labels/team", Path: "/metadata/labels/owner"}},
+			contains: []string{"owner: payments"},
+			excludes: []string{"team: payments"},
+		},
+		{
+			name:     "copy",
+			patches:  []PatchOperation{{Op: PatchOpCopy, From: "/metadata/labels/team", Path: "/metadata/labels/owner"}},
+			contains: []string{"owner: payments", "team: payments"},
+		},
+		{
+			name:    "replace of a missing path",
+			patches: []PatchOperation{{Op: PatchOpReplace, Path: "/spec/paused", Value: rawValue("true")}},
+			wantErr: "path does not exist",
+		},
+		{
+			name:    "replace out of array bounds",
+			patches: []PatchOperation{{Op: PatchOpReplace, Path: "/spec/template/spec/containers/1/image", Value: rawValue(`"app:2.0"`)}},
+			wantErr: "path does not exist",
+		},
+		{
+			name:    "relative path",
+			patches: []PatchOperation{{Op: PatchOpReplace, Path: "spec/replicas", Value: rawValue("3")}},
+			wantErr: "path must start with /",
+		},
+		{
+			name:    "remove of a missing path",
+			patches: []PatchOperation{{Op: PatchOpRemove, Path: "/spec/paused"}},
+			wantErr: "patch operation 0 (remove /spec/paused)",
+		},
+		{
+			name:    "test mismatch",
+			patches: []PatchOperation{{Op: PatchOpReplace, Path: "/spec/replicas", Value: rawValue("2")}, {Op: PatchOpTest, Path: "/spec/replicas", Value: rawValue("1")}},
+			wantErr: "patch operation 1 (test /spec/replicas)",
+		},
+		{
+			name:    "add without value",
+			patches: []PatchOperation{{Op: PatchOpAdd, Path: "/spec/paused"}},
+			wantErr: "add operation requires a value",
+		},
+		{
+			name:    "test without value",
+			patches: []PatchOperation{{Op: PatchOpTest, Path: "/spec/replicas"}},
+			wantErr: "test operation requires a value",
+		},
+		{
+			name:    "move without from",
+			patches: []PatchOperation{{Op: PatchOpMove, Path: "/metadata/labels/owner"}},
+			wantErr: "move operation requires from",
+		},
+		{
+			name:    "copy without from",
+			patches: []PatchOperation{{Op: PatchOpCopy, Path: "/metadata/labels/owner"}},
+			wantErr: "copy operation requires from",
+		},
+		{
+			name:    "unknown operation",
+			patches: []PatchOperation{{Op: "merge", Path: "/spec", Value: rawValue("{}")}},
+			wantErr: "unknown operation merge",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			patched, err := ProcessPatches([]byte(deployment), test.patches)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", test.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, want := range test.contains {
+				if !strings.Contains(string(patched), want) {
+					t.Errorf("patched resource does not contain %q:\n%s", want, patched)
+				}
+			}
+			for _, unwanted := range test.excludes {
+				if strings.Contains(string(patched), unwanted) {
+					t.Errorf("patched resource contains %q:\n%s", unwanted, patched)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// ErrURLNotAllowed is returned when a URL or the address it resolves to is not allowed by the URL policy
+var ErrURLNotAllowed = errors.New("not allowed by the URL policy")
+
+// DefaultAllowedSchemes are the schemes URL sources may use if no schemes are configured
+var DefaultAllowedSchemes = []string{"https", "http"}
+
+// alwaysBlockedCIDRs are the networks URL sources may never be fetched from unless explicitly allowed
+var alwaysBlockedCIDRs = []string{
+
+	// Unspecified and loopback
+	"0.0.0.0/8",
+	"127.0.0.0/8",
+	"::/128",
+	"::1/128",
+
+	// Link-local, including cloud metadata endpoints
+	"169.254.0.0/16",
+	"fe80::/10",
+
+	// Private networks, which the pod and service networks of the cluster are allocated from
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10",
+	"fc00::/7",
+}
+
+// urlPolicy decides which URLs and addresses URL sources may be fetched from
+type urlPolicy struct {
+	allowedSchemes  []string
+	allowedHosts    []string
+	allowedPrefixes []*url.URL
+	allowedNetworks []*net.IPNet
+	blockedNetworks []*net.IPNet
+}
+
+// newURLPolicy parses the URL policy of the HTTP configuration
+func newURLPolicy(httpConfig HTTPConfig) (*urlPolicy, error) {
+
+	policy := &urlPolicy{
+		allowedSchemes: httpConfig.AllowedSchemes,
+		allowedHosts:   httpConfig.AllowedHosts,
+	}
+	if len(policy.allowedSchemes) == 0 {
+		policy.allowedSchemes = DefaultAllowedSchemes
+	}
+
+	for _, prefix := range httpConfig.AllowedURLPrefixes {
+		prefixURL, err := url.Parse(prefix)
+		if err != nil || prefixURL.Scheme == "" || prefixURL.Host == "" {
+			return nil, errors.New("allowed URL prefix " + prefix + " must be an absolute URL")
+		}
+		policy.allowedPrefixes = append(policy.allowedPrefixes, prefixURL)
+	}
+
+	parseNetworks := func(cidrs []string) ([]*net.IPNet, error) {
+		networks := make([]*net.IPNet, 0, len(cidrs))
+		for _, cidr := range cidrs {
+			_, network, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return nil, errors.New("invalid CIDR " + cidr + ": " + err.Error())
+			}
+			networks = append(networks, network)
+		}
+		return networks, nil
+	}
+
+	var err error
+	if policy.allowedNetworks, err = parseNetworks(httpConfig.AllowedCIDRs); err != nil {
+		return nil, err
+	}
+	if policy.blockedNetworks, err = parseNetworks(append(append([]string(nil), alwaysBlockedCIDRs...), httpConfig.BlockedCIDRs...)); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// checkURL ensures the URL uses an allowed scheme and matches the allowed hosts or URL prefixes if any are configured
+func (p *urlPolicy) checkURL(requestURL *url.URL) error {
+
+	if !containsFold(p.allowedSchemes, requestURL.Scheme) {
+		return fmt.Errorf("%s: scheme %q is %w", redactURL(requestURL), requestURL.Scheme, ErrURLNotAllowed)
+	}
+
+	// Any host is allowed if no allowlist is configured
+	if len(p.allowedHosts) == 0 && len(p.allowedPrefixes) == 0 {
+		return nil
+	}
+
+	for _, allowedHost := range p.allowedHosts {
+		if hostMatches(requestURL.Hostname(), allowedHost) {
+			return nil
+		}
+	}
+	for _, prefix := range p.allowedPrefixes {
+		if strings.EqualFold(requestURL.Scheme, prefix.Scheme) && strings.EqualFold(requestURL.Host, prefix.Host) && pathHasPrefix(requestURL.Path, prefix.Path) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s: URL is %w", redactURL(requestURL), ErrURLNotAllowed)
+}
+
+// checkAddress ensures the resolved address is not within a blocked network, unless it is explicitly allowed
+func (p *urlPolicy) checkAddress(ip net.IP) error {
+
+	for _, network := range p.allowedNetworks {
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+
+	for _, network := range p.blockedNetworks {
+		if network.Contains(ip) {
+			return fmt.Errorf("address %s is %w", ip, ErrURLNotAllowed)
+		}
+	}
+
+	return nil
+}
+
+// dialControl refuses connections to blocked addresses after the host name was resolved
+func (p *urlPolicy) dialControl(network string, address string, _ syscall.RawConn) error {
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("address %s is %w", host, ErrURLNotAllowed)
+	}
+
+	return p.checkAddress(ip)
+}
+
+// checkHost resolves the host and ensures none of its addresses is blocked, as the connections of proxied requests are made by the proxy
+func (p *urlPolicy) checkHost(ctx context.Context, host string) error {
+
+	if ip := net.ParseIP(host); ip != nil {
+		return p.checkAddress(ip)
+	}
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, address := range addresses {
+		if err := p.checkAddress(address.IP); err != nil {
+			return fmt.Errorf("%s: %w", host, err)
+		}
+	}
+
+	return nil
+}
+
+// policyTransport checks every request, including redirects, against the URL policy before sending it
+type policyTransport struct {
+	policy    *urlPolicy
+	transport *http.Transport
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *policyTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+
+	if err := t.policy.checkURL(request.URL); err != nil {
+		return nil, err
+	}
+
+	// Only the connection to the proxy is checked when dialing, so check the address of the URL itself
+	if t.transport.Proxy != nil {
+		proxyURL, err := t.transport.Proxy(request)
+		if err != nil {
+			return nil, err
+		}
+		if proxyURL != nil {
+			if err := t.policy.checkHost(request.Context(), request.URL.Hostname()); err != nil {
+				return nil, fmt.Errorf("%s: %w", redactURL(request.URL), err)
+			}
+		}
+	}
+
+	return t.transport.RoundTrip(request)
+}
+
+// redactURL returns the URL without its credentials and query, which may hold secrets
+func redactURL(requestURL *url.URL) string {
+	return requestURL.Scheme + "://" + requestURL.Host + requestURL.Path
+}
+
+// hostMatches checks whether the host equals the allowed host or is a subdomain of a *. prefixed allowed host
+func hostMatches(host string, allowedHost string) bool {
+
+	if strings.HasPrefix(allowedHost, "*.") {
+		return strings.HasSuffix(strings.ToLower(host), strings.ToLower(allowedHost[1:]))
+	}
+
+	return strings.EqualFold(host, allowedHost)
+}
+
+// pathHasPrefix checks whether the path is within the prefix path, respecting path segments
+func pathHasPrefix(path string, prefix string) bool {
+
+	if prefix == "" || prefix == "/" || path == prefix {
+		return true
+	}
+
+	return strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+// containsFold checks whether the list contains the value, ignoring case
+func containsFold(list []string, value string) bool {
+
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+
+	return false
+}
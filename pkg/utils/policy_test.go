@@ -0,0 +1,239 @@
+package utils
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPolicyTransportProxy(t *testing.T) {
+
+	// The proxy answers every request itself and is exempted from the blocked networks
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	client, err := newHTTPClient(HTTPConfig{
+		Proxy:        proxy.URL,
+		AllowedCIDRs: []string{"127.0.0.0/8"},
+	}.WithDefaults())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{url: "http://93.184.216.34/object.yaml", allowed: true},
+		{url: "http://169.254.169.254/latest/meta-data/", allowed: false},
+		{url: "http://10.0.0.1/object.yaml", allowed: false},
+		{url: "http://[fe80::1]/object.yaml", allowed: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+
+			response, err := client.Get(test.url)
+			if err == nil {
+				response.Body.Close()
+			}
+
+			if test.allowed && err != nil {
+				t.Errorf("expected the request to be sent through the proxy, got %v", err)
+			} else if !test.allowed && !errors.Is(err, ErrURLNotAllowed) {
+				t.Errorf("expected the request to be refused by the URL policy, got %v", err)
+			}
+		})
+	}
+}
+
+func TestGitURLPolicy(t *testing.T) {
+
+	sourceStruct := SourceStruct{Git: &GitSource{URL: "http://169.254.169.254/repository.git", Path: "object.yaml"}}
+	_, _, err := getManagedResourceBytesByGit(sourceStruct, SourceContext{Namespace: "default"})
+	if err == nil || !strings.Contains(err.Error(), ErrURLNotAllowed.Error()) {
+		t.Errorf("expected the repository to be refused by the URL policy, got %v", err)
+	}
+}
+
+func TestURLPolicyCheckURL(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		config  HTTPConfig
+		url     string
+		allowed bool
+	}{
+		{name: "default schemes", url: "https://example.com/object.yaml", allowed: true},
+		{name: "default schemes http", url: "http://example.com/object.yaml", allowed: true},
+		{name: "default schemes file", url: "file:///etc/passwd", allowed: false},
+		{name: "default schemes ftp", url: "ftp://example.com/object.yaml", allowed: false},
+		{name: "scheme case", url: "HTTPS://example.com/object.yaml", allowed: true},
+		{name: "configured schemes", config: HTTPConfig{AllowedSchemes: []string{"https"}}, url: "http://example.com/object.yaml", allowed: false},
+		{name: "allowed host", config: HTTPConfig{AllowedHosts: []string{"example.com"}}, url: "https://example.com/object.yaml", allowed: true},
+		{name: "allowed host case", config: HTTPConfig{AllowedHosts: []string{"example.com"}}, url: "https://EXAMPLE.com/object.yaml", allowed: true},
+		{name: "allowed host with port", config: HTTPConfig{AllowedHosts: []string{"example.com"}}, url: "https://example.com:8443/object.yaml", allowed: true},
+		{name: "other host", config: HTTPConfig{AllowedHosts: []string{"example.com"}}, url: "https://example.org/object.yaml", allowed: false},
+		{name: "subdomain of exact host", config: HTTPConfig{AllowedHosts: []string{"example.com"}}, url: "https://sub.example.com/object.yaml", allowed: false},
+		{name: "wildcard subdomain", config: HTTPConfig{AllowedHosts: []string{"*.example.com"}}, url: "https://sub.example.com/object.yaml", allowed: true},
+		{name: "wildcard apex", config: HTTPConfig{AllowedHosts: []string{"*.example.com"}}, url: "https://example.com/object.yaml", allowed: false},
+		{name: "wildcard suffix", config: HTTPConfig{AllowedHosts: []string{"*.example.com"}}, url: "https://attackerexample.com/object.yaml", allowed: false},
+		{name: "allowed prefix", config: HTTPConfig{AllowedURLPrefixes: []string{"https://example.com/manifests/"}}, url: "https://example.com/manifests/object.yaml", allowed: true},
+		{name: "allowed prefix without slash", config: HTTPConfig{AllowedURLPrefixes: []string{"https://example.com/manifests"}}, url: "https://example.com/manifests/object.yaml", allowed: true},
+		{name: "prefix segment boundary", config: HTTPConfig{AllowedURLPrefixes: []string{"https://example.com/manifests"}}, url: "https://example.com/manifests-private/object.yaml", allowed: false},
+		{name: "prefix scheme", config: HTTPConfig{AllowedURLPrefixes: []string{"https://example.com/manifests/"}}, url: "http://example.com/manifests/object.yaml", allowed: false},
+		{name: "prefix port", config: HTTPConfig{AllowedURLPrefixes: []string{"https://example.com/manifests/"}}, url: "https://example.com:8443/manifests/object.yaml", allowed: false},
+		{name: "prefix host case", config: HTTPConfig{AllowedURLPrefixes: []string{"https://example.com/manifests/"}}, url: "https://Example.COM/manifests/object.yaml", allowed: true},
+		{name: "host or prefix", config: HTTPConfig{AllowedHosts: []string{"example.org"}, AllowedURLPrefixes: []string{"https://example.com/manifests/"}}, url: "https://example.org/object.yaml", allowed: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			policy, err := newURLPolicy(test.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			requestURL, err := url.Parse(test.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = policy.checkURL(requestURL)
+			if test.allowed && err != nil {
+				t.Errorf("expected the URL to be allowed, got %v", err)
+			} else if !test.allowed && !errors.Is(err, ErrURLNotAllowed) {
+				t.Errorf("expected the URL to be refused by the URL policy, got %v", err)
+			}
+		})
+	}
+}
+
+func TestURLPolicyCheckAddress(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		config  HTTPConfig
+		ip      string
+		allowed bool
+	}{
+		{name: "public", ip: "93.184.216.34", allowed: true},
+		{name: "public IPv6", ip: "2606:2800:220:1:248:1893:25c8:1946", allowed: true},
+		{name: "unspecified", ip: "0.0.0.0", allowed: false},
+		{name: "loopback", ip: "127.0.0.1", allowed: false},
+		{name: "IPv6 loopback", ip: "::1", allowed: false},
+		{name: "IPv4 mapped loopback", ip: "::ffff:127.0.0.1", allowed: false},
+		{name: "metadata", ip: "169.254.169.254", allowed: false},
+		{name: "IPv6 link-local", ip: "fe80::1", allowed: false},
+		{name: "private", ip: "10.96.0.1", allowed: false},
+		{name: "private 172", ip: "172.20.0.1", allowed: false},
+		{name: "private 192", ip: "192.168.1.1", allowed: false},
+		{name: "shared address space", ip: "100.64.0.1", allowed: false},
+		{name: "unique local", ip: "fd00::1", allowed: false},
+		{name: "allowed network", config: HTTPConfig{AllowedCIDRs: []string{"10.1.0.0/16"}}, ip: "10.1.2.3", allowed: true},
+		{name: "outside allowed network", config: HTTPConfig{AllowedCIDRs: []string{"10.1.0.0/16"}}, ip: "10.2.0.1", allowed: false},
+		{name: "blocked network", config: HTTPConfig{BlockedCIDRs: []string{"93.184.216.0/24"}}, ip: "93.184.216.34", allowed: false},
+		{name: "allowed within blocked network", config: HTTPConfig{AllowedCIDRs: []string{"93.184.216.34/32"}, BlockedCIDRs: []string{"93.184.216.0/24"}}, ip: "93.184.216.34", allowed: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			policy, err := newURLPolicy(test.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = policy.checkAddress(net.ParseIP(test.ip))
+			if test.allowed && err != nil {
+				t.Errorf("expected the address to be allowed, got %v", err)
+			} else if !test.allowed && !errors.Is(err, ErrURLNotAllowed) {
+				t.Errorf("expected the address to be refused by the URL policy, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewURLPolicy(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		config  HTTPConfig
+		wantErr bool
+	}{
+		{name: "defaults"},
+		{name: "relative prefix", config: HTTPConfig{AllowedURLPrefixes: []string{"/manifests"}}, wantErr: true},
+		{name: "prefix without host", config: HTTPConfig{AllowedURLPrefixes: []string{"https:///manifests"}}, wantErr: true},
+		{name: "invalid allowed CIDR", config: HTTPConfig{AllowedCIDRs: []string{"10.0.0.1"}}, wantErr: true},
+		{name: "invalid blocked CIDR", config: HTTPConfig{BlockedCIDRs: []string{"10.0.0.0/33"}}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := newURLPolicy(test.config); (err != nil) != test.wantErr {
+				t.Errorf("newURLPolicy() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestHostMatches(t *testing.T) {
+
+	tests := []struct {
+		host        string
+		allowedHost string
+		matches     bool
+	}{
+		{host: "example.com", allowedHost: "example.com", matches: true},
+		{host: "EXAMPLE.com", allowedHost: "example.COM", matches: true},
+		{host: "example.org", allowedHost: "example.com", matches: false},
+		{host: "sub.example.com", allowedHost: "example.com", matches: false},
+		{host: "sub.example.com", allowedHost: "*.example.com", matches: true},
+		{host: "a.b.example.com", allowedHost: "*.example.com", matches: true},
+		{host: "SUB.Example.com", allowedHost: "*.example.COM", matches: true},
+		{host: "example.com", allowedHost: "*.example.com", matches: false},
+		{host: "attackerexample.com", allowedHost: "*.example.com", matches: false},
+		{host: "example.com.attacker.org", allowedHost: "*.example.com", matches: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.host+" "+test.allowedHost, func(t *testing.T) {
+			if matches := hostMatches(test.host, test.allowedHost); matches != test.matches {
+				t.Errorf("hostMatches(%s, %s) = %v, want %v", test.host, test.allowedHost, matches, test.matches)
+			}
+		})
+	}
+}
+
+func TestPathHasPrefix(t *testing.T) {
+
+	tests := []struct {
+		path    string
+		prefix  string
+		matches bool
+	}{
+		{path: "/anything", prefix: "", matches: true},
+		{path: "/anything", prefix: "/", matches: true},
+		{path: "/manifests", prefix: "/manifests", matches: true},
+		{path: "/manifests/object.yaml", prefix: "/manifests", matches: true},
+		{path: "/manifests/object.yaml", prefix: "/manifests/", matches: true},
+		{path: "/manifests/team/object.yaml", prefix: "/manifests/", matches: true},
+		{path: "/manifests-private/object.yaml", prefix: "/manifests", matches: false},
+		{path: "/manifests", prefix: "/manifests/", matches: false},
+		{path: "/other/manifests/object.yaml", prefix: "/manifests", matches: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path+" "+test.prefix, func(t *testing.T) {
+			if matches := pathHasPrefix(test.path, test.prefix); matches != test.matches {
+				t.Errorf("pathHasPrefix(%s, %s) = %v, want %v", test.path, test.prefix, matches, test.matches)
+			}
+		})
+	}
+}
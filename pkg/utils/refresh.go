@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultRefreshInterval is the time between fetches of a refreshed URL source if no interval is set
+const DefaultRefreshInterval = 5 * time.Minute
+
+// SourceRefresh defines how often a URL source is fetched again
+type SourceRefresh struct {
+
+	// Interval is the time between fetches of the URL, defaults to 5m
+	// +optional
+	Interval metav1.Duration `json:"interval,omitempty"`
+}
+
+// RefreshInterval returns the time between fetches of the URL
+func (r SourceRefresh) RefreshInterval() time.Duration {
+
+	if r.Interval.Duration <= 0 {
+		return DefaultRefreshInterval
+	}
+
+	return r.Interval.Duration
+}
+
+// Digest returns the SHA-256 digest of the source contents
+func Digest(contents []byte) string {
+	sum := sha256.Sum256(contents)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
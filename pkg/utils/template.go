@@ -0,0 +1,350 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode/utf8"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// MaxRenderedSize is the largest output a source template may render
+const MaxRenderedSize = 4 << 20
+
+// templateFunctions are the functions a source template may call in addition to the allowed builtins, each of which limits the size of its result
+var templateFunctions = template.FuncMap{
+	"default": func(defaultValue string, value string) string {
+		if value == "" {
+			return defaultValue
+		}
+		return value
+	},
+	"required": func(message string, value string) (string, error) {
+		if value == "" {
+			return "", errors.New(message)
+		}
+		return value, nil
+	},
+	"quote":   templateStringFunction(strconv.Quote),
+	"lower":   templateStringFunction(strings.ToLower),
+	"upper":   templateStringFunction(strings.ToUpper),
+	"trim":    strings.TrimSpace,
+	"replace": templateReplace,
+	"print":   templatePrint(fmt.Sprint),
+	"println": templatePrint(fmt.Sprintln),
+	"printf":  templatePrintf,
+}
+
+// allowedBuiltins are the builtin template functions which neither call arbitrary functions, escape for other languages nor build large values, print functions are replaced by limited ones
+var allowedBuiltins = map[string]bool{
+	"and": true, "or": true, "not": true,
+	"eq": true, "ne": true, "lt": true, "le": true, "gt": true, "ge": true,
+	"len": true, "index": true, "slice": true,
+}
+
+// checkTemplateValue ensures a value built by a template function is not larger than a rendered source may be
+func checkTemplateValue(value string) (string, error) {
+
+	if len(value) > MaxRenderedSize {
+		return "", errors.New("template value is larger than " + strconv.Itoa(MaxRenderedSize) + " bytes")
+	}
+
+	return value, nil
+}
+
+// templateStringFunction returns a function which checks the value before converting it, as quoting or changing the case of a value at most quadruples its size, and checks the result after
+func templateStringFunction(convert func(string) string) func(string) (string, error) {
+	return func(value string) (string, error) {
+
+		if _, err := checkTemplateValue(value); err != nil {
+			return "", err
+		}
+
+		return checkTemplateValue(convert(value))
+	}
+}
+
+// templateReplace replaces all occurrences of old with new, refusing to build a result larger than a rendered source may be
+func templateReplace(value string, old string, new string) (string, error) {
+
+	// Compute the size of the result before allocating it
+	size := len(value)
+	if old == "" {
+		size += (utf8.RuneCountInString(value) + 1) * len(new)
+	} else {
+		size += strings.Count(value, old) * (len(new) - len(old))
+	}
+	if size > MaxRenderedSize {
+		return "", errors.New("replace result is larger than " + strconv.Itoa(MaxRenderedSize) + " bytes")
+	}
+
+	return strings.ReplaceAll(value, old, new), nil
+}
+
+// templatePrint returns a print function which refuses to build a result larger than a rendered source may be
+func templatePrint(print func(args ...interface{}) string) func(args ...interface{}) (string, error) {
+	return func(args ...interface{}) (string, error) {
+
+		// Each operand is followed by at most a space or a newline
+		size := 0
+		for _, arg := range args {
+			if size += len(fmt.Sprint(arg)) + 1; size > MaxRenderedSize {
+				return "", errors.New("print result is larger than " + strconv.Itoa(MaxRenderedSize) + " bytes")
+			}
+		}
+
+		return print(args...), nil
+	}
+}
+
+// templatePrintf formats the arguments, refusing formats whose result may be larger than a rendered source may be
+func templatePrintf(format string, args ...interface{}) (string, error) {
+
+	// The format may not repeat operands or pad them beyond the limit
+	size, err := formatSize(format)
+	if err != nil {
+		return "", errors.New("printf: " + err.Error())
+	}
+
+	// Formatting an operand at most quadruples its quoted form, such as hex encoding with spaces or escaping control characters
+	for _, arg := range args {
+		size += 4*len(fmt.Sprintf("%#v", arg)) + 64
+		if size > MaxRenderedSize {
+			return "", errors.New("printf result may be larger than " + strconv.Itoa(MaxRenderedSize) + " bytes")
+		}
+	}
+
+	return fmt.Sprintf(format, args...), nil
+}
+
+// formatSize returns the size of a printf format along with the widths and precisions of its verbs, which may neither index operands nor take their width or precision from an operand
+func formatSize(format string) (int, error) {
+
+	size := len(format)
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+
+		// Skip flags
+		for i++; i < len(format) && strings.IndexByte("+-# 0", format[i]) >= 0; i++ {
+		}
+
+		// Read width and precision
+		for _, separator := range []byte{0, '.'} {
+			if separator != 0 {
+				if i >= len(format) || format[i] != separator {
+					continue
+				}
+				i++
+			}
+
+			number := 0
+			for ; i < len(format) && format[i] >= '0' && format[i] <= '9'; i++ {
+				if number = number*10 + int(format[i]-'0'); number > MaxRenderedSize {
+					return 0, errors.New("width or precision is larger than " + strconv.Itoa(MaxRenderedSize))
+				}
+			}
+			size += number
+		}
+
+		if i < len(format) && (format[i] == '*' || format[i] == '[') {
+			return 0, errors.New("operand widths and indexes are not supported")
+		}
+		if size > MaxRenderedSize {
+			return 0, errors.New("format may render more than " + strconv.Itoa(MaxRenderedSize) + " bytes")
+		}
+	}
+
+	return size, nil
+}
+
+// templateData is the data a source template is rendered with
+type templateData struct {
+	Namespace  string
+	Name       string
+	Labels     map[string]string
+	Parameters map[string]string
+}
+
+// renderTemplate renders the source contents as a template with the managed resource namespace, name, labels and parameters
+func renderTemplate(contents []byte, sourceContext SourceContext) ([]byte, error) {
+
+	// Parse template, failing on parameters which are not set
+	sourceTemplate, err := template.New("source").Option("missingkey=error").Funcs(templateFunctions).Parse(string(contents))
+	if err != nil {
+		return nil, errors.New("an error occurred while parsing source template: " + err.Error())
+	}
+	if err := checkTemplate(sourceTemplate); err != nil {
+		return nil, errors.New("an error occurred while parsing source template: " + err.Error())
+	}
+
+	// Read parameters, where the managed resource parameters take precedence over the config map
+	parameters := make(map[string]string)
+	if sourceContext.ParametersConfigMap != "" {
+		configMap := &corev1.ConfigMap{}
+		if err := sourceContext.Client.Get(context.Background(), types.NamespacedName{Namespace: sourceContext.Namespace, Name: sourceContext.ParametersConfigMap}, configMap); err != nil {
+			return nil, errors.New("an error occurred while reading parameters config map " + sourceContext.ParametersConfigMap + ": " + err.Error())
+		}
+		for key, value := range configMap.Data {
+			parameters[key] = value
+		}
+	}
+	for key, value := range sourceContext.Parameters {
+		parameters[key] = value
+	}
+
+	labels := sourceContext.Labels
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+
+	// Render template, stopping once the output grows too large
+	rendered := &limitedBuffer{limit: MaxRenderedSize}
+	if err := sourceTemplate.Execute(rendered, templateData{
+		Namespace:  sourceContext.Namespace,
+		Name:       sourceContext.Name,
+		Labels:     labels,
+		Parameters: parameters,
+	}); err != nil {
+		return nil, errors.New("an error occurred while rendering source template: " + err.Error())
+	}
+
+	return rendered.Bytes(), nil
+}
+
+// checkTemplate ensures the template only uses the supported subset of the template language
+func checkTemplate(sourceTemplate *template.Template) error {
+
+	if len(sourceTemplate.Templates()) > 1 {
+		return errors.New("defining templates is not supported")
+	}
+
+	return checkTemplateNode(sourceTemplate.Tree.Root, false)
+}
+
+// checkTemplateNode ensures the node and its children only use supported actions and functions, where inRange tells whether the node is within a range action
+func checkTemplateNode(node parse.Node, inRange bool) error {
+
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return nil
+		}
+		for _, child := range node.Nodes {
+			if err := checkTemplateNode(child, inRange); err != nil {
+				return err
+			}
+		}
+	case *parse.TextNode:
+	case *parse.ActionNode:
+		return checkTemplatePipe(node.Pipe)
+	case *parse.IfNode:
+		return checkTemplateBranch(node.BranchNode, inRange)
+	case *parse.WithNode:
+		return checkTemplateBranch(node.BranchNode, inRange)
+	case *parse.RangeNode:
+
+		// Only ranging once over the labels or parameters avoids loops of arbitrary length,
+		// as nested ranges multiply their iterations without rendering anything
+		if inRange {
+			return errors.New("nested range is not supported")
+		}
+		if !isRangeOverMap(node.Pipe) {
+			return fmt.Errorf("range over %s is not supported, only .Labels and .Parameters may be ranged over", node.Pipe)
+		}
+		return checkTemplateBranch(node.BranchNode, true)
+	default:
+		return fmt.Errorf("%s is not supported", node)
+	}
+
+	return nil
+}
+
+// isRangeOverMap checks whether the range pipeline is either the labels or the parameters of the template data
+func isRangeOverMap(pipe *parse.PipeNode) bool {
+
+	if len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+
+	var fields []string
+	switch arg := pipe.Cmds[0].Args[0].(type) {
+	case *parse.FieldNode:
+		fields = arg.Ident
+	case *parse.VariableNode:
+		if len(arg.Ident) == 0 || arg.Ident[0] != "$" {
+			return false
+		}
+		fields = arg.Ident[1:]
+	}
+
+	return len(fields) == 1 && (fields[0] == "Labels" || fields[0] == "Parameters")
+}
+
+// checkTemplateBranch ensures the condition and both branches of an if, with or range action are supported
+func checkTemplateBranch(node parse.BranchNode, inRange bool) error {
+
+	if err := checkTemplatePipe(node.Pipe); err != nil {
+		return err
+	}
+	if err := checkTemplateNode(node.List, inRange); err != nil {
+		return err
+	}
+
+	return checkTemplateNode(node.ElseList, inRange)
+}
+
+// checkTemplatePipe ensures the pipeline only calls allowed functions
+func checkTemplatePipe(pipe *parse.PipeNode) error {
+
+	if pipe == nil {
+		return nil
+	}
+
+	for _, command := range pipe.Cmds {
+		for _, arg := range command.Args {
+			switch arg := arg.(type) {
+			case *parse.IdentifierNode:
+				if _, ok := templateFunctions[arg.Ident]; !ok && !allowedBuiltins[arg.Ident] {
+					return errors.New("function " + arg.Ident + " is not supported")
+				}
+			case *parse.PipeNode:
+				if err := checkTemplatePipe(arg); err != nil {
+					return err
+				}
+			case *parse.ChainNode:
+				if pipeArg, ok := arg.Node.(*parse.PipeNode); ok {
+					if err := checkTemplatePipe(pipeArg); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// limitedBuffer is a buffer which refuses writes past its limit
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+// Write implements io.Writer
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+
+	if b.Len()+len(p) > b.limit {
+		return 0, errors.New("rendered source is larger than " + strconv.Itoa(b.limit) + " bytes")
+	}
+
+	return b.Buffer.Write(p)
+}
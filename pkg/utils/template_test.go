@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestRenderTemplateLimits(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		template string
+		rendered string
+		wantErr  bool
+	}{
+		{name: "printf", template: `{{ printf "%s-%05d-%.2f" .Name 42 1.5 }}`, rendered: "object-00042-1.50"},
+		{name: "printf percent", template: `{{ printf "100%%" }}`, rendered: "100%"},
+		{name: "print", template: `{{ print .Name 1 2 }}`, rendered: "object1 2"},
+		{name: "println", template: `{{ println .Name }}`, rendered: "object\n"},
+		{name: "replace", template: `{{ replace .Name "o" "0" }}`, rendered: "0bject"},
+		{name: "replace empty", template: `{{ replace "ab" "" "-" }}`, rendered: "-a-b-"},
+		{name: "quote", template: `{{ quote .Name }}`, rendered: `"object"`},
+		{name: "replace amplification", template: `{{ replace (printf "%01000000d" 0) "0" (printf "%01000000d" 0) }}`, wantErr: true},
+		{name: "replace empty amplification", template: `{{ replace (printf "%01000000d" 0) "" (printf "%01000000d" 0) }}`, wantErr: true},
+		{name: "printf width", template: `{{ printf "%0999999999d" 0 }}`, wantErr: true},
+		{name: "printf precision", template: `{{ printf "%.999999999f" 1.0 }}`, wantErr: true},
+		{name: "printf width overflow", template: `{{ printf "%099999999999999999999999d" 0 }}`, wantErr: true},
+		{name: "printf operand width", template: `{{ printf "%*d" 999999999 0 }}`, wantErr: true},
+		{name: "printf operand precision", template: `{{ printf "%.*f" 999999999 1.0 }}`, wantErr: true},
+		{name: "printf operand index", template: `{{ printf "%[1]s%[1]s" .Name }}`, wantErr: true},
+		{name: "printf operands", template: `{{ $x := printf "%04000000d" 0 }}{{ printf "%s%s" $x $x }}`, wantErr: true},
+		{name: "print operands", template: `{{ $x := printf "%04000000d" 0 }}{{ print $x $x }}`, wantErr: true},
+		{name: "quote amplification", template: `{{ quote (quote (replace (printf "%0900000d" 0) "0" (printf "%c" 1))) }}`, wantErr: true},
+		{name: "rendered size", template: `{{ $x := printf "%03000000d" 0 }}{{ $x }}{{ $x }}`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			rendered, err := renderTemplate([]byte(test.template), SourceContext{Namespace: "default", Name: "object"})
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, rendered %d bytes", len(rendered))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(rendered) != test.rendered {
+				t.Errorf("rendered %q, want %q", rendered, test.rendered)
+			}
+		})
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+
+	tests := []struct {
+		format  string
+		size    int
+		wantErr bool
+	}{
+		{format: "plain", size: 5},
+		{format: "%s", size: 2},
+		{format: "%10s", size: 14},
+		{format: "%-08.3f", size: 18},
+		{format: "%%", size: 2},
+		{format: "%", size: 1},
+		{format: "%*d", wantErr: true},
+		{format: "%.*d", wantErr: true},
+		{format: "%[2]d", wantErr: true},
+		{format: "%3[2]d", wantErr: true},
+		{format: strings.Repeat("%4000000d", 2), wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			size, err := formatSize(test.format)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("formatSize() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if err == nil && size != test.size {
+				t.Errorf("formatSize() = %d, want %d", size, test.size)
+			}
+		})
+	}
+}
+
+func TestCheckTemplate(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		template string
+		wantErr  bool
+	}{
+		{name: "text", template: "kind: ConfigMap"},
+		{name: "field", template: "name: {{ .Name }}"},
+		{name: "comment", template: "{{/* comment */}}"},
+		{name: "allowed functions", template: `{{ default "a" .Name | upper | quote }}{{ replace .Name "-" "." }}{{ printf "%s" (lower .Name) }}`},
+		{name: "allowed builtins", template: `{{ if and (eq .Name "a") (not (lt (len .Name) 2)) }}{{ index .Labels "team" }}{{ end }}`},
+		{name: "if else", template: `{{ if .Name }}a{{ else if .Namespace }}b{{ else }}{{ required "name" .Name }}{{ end }}`},
+		{name: "with", template: `{{ with .Name }}{{ . | trim }}{{ end }}`},
+		{name: "range labels", template: `{{ range $key, $value := .Labels }}{{ $key }}={{ $value }}{{ end }}`},
+		{name: "range parameters", template: `{{ range $key, $value := $.Parameters }}{{ $key }}{{ end }}`},
+		{name: "sequential ranges", template: `{{ range .Labels }}{{ . }}{{ end }}{{ range .Parameters }}{{ . }}{{ end }}`},
+		{name: "nested range", template: `{{ range .Parameters }}{{ range .Labels }}{{ end }}{{ end }}`, wantErr: true},
+		{name: "nested range within a condition", template: `{{ range .Parameters }}{{ if . }}{{ range $.Labels }}{{ end }}{{ end }}{{ end }}`, wantErr: true},
+		{name: "define", template: `{{ define "other" }}a{{ end }}`, wantErr: true},
+		{name: "template", template: `{{ template "source" }}`, wantErr: true},
+		{name: "block", template: `{{ block "other" . }}a{{ end }}`, wantErr: true},
+		{name: "range over field", template: `{{ range .Name }}{{ end }}`, wantErr: true},
+		{name: "range over nested field", template: `{{ range .Labels.team }}{{ end }}`, wantErr: true},
+		{name: "range over function", template: `{{ range slice .Name 1 }}{{ end }}`, wantErr: true},
+		{name: "range over variable", template: `{{ $labels := .Labels }}{{ range $labels }}{{ end }}`, wantErr: true},
+		{name: "call", template: `{{ call .Name }}`, wantErr: true},
+		{name: "html", template: `{{ html .Name }}`, wantErr: true},
+		{name: "js", template: `{{ js .Name }}`, wantErr: true},
+		{name: "urlquery", template: `{{ urlquery .Name }}`, wantErr: true},
+		{name: "disallowed function in pipeline", template: `{{ .Name | html }}`, wantErr: true},
+		{name: "disallowed function in argument", template: `{{ quote (js .Name) }}`, wantErr: true},
+		{name: "disallowed function in condition", template: `{{ if call .Name }}{{ end }}`, wantErr: true},
+		{name: "disallowed function in else branch", template: `{{ if .Name }}{{ else }}{{ html .Name }}{{ end }}`, wantErr: true},
+		{name: "disallowed function in range", template: `{{ range .Labels }}{{ js . }}{{ end }}`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			sourceTemplate, err := template.New("source").Funcs(templateFunctions).Parse(test.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := checkTemplate(sourceTemplate); (err != nil) != test.wantErr {
+				t.Errorf("checkTemplate() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestTemplateStringFunctions(t *testing.T) {
+
+	large := strings.Repeat("a", MaxRenderedSize+1)
+	for _, name := range []string{"quote", "lower", "upper"} {
+		function := templateFunctions[name].(func(string) (string, error))
+
+		if _, err := function("value"); err != nil {
+			t.Errorf("%s() error = %v", name, err)
+		}
+		if _, err := function(large); err == nil {
+			t.Errorf("%s() of a value larger than a rendered source should fail", name)
+		}
+	}
+
+	// Quoting control characters quadruples their size
+	if _, err := templateFunctions["quote"].(func(string) (string, error))(strings.Repeat("\x01", MaxRenderedSize/2)); err == nil {
+		t.Error("quote() of a value whose result is larger than a rendered source should fail")
+	}
+}
@@ -1,20 +1,26 @@
 package utils
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
 	"errors"
-	"io/ioutil"
+	"fmt"
+	"net"
 	"net/http"
-	"os"
+	"net/url"
 	"reflect"
-	"strconv"
+	"sync"
 	"time"
 
 	"github.com/imdario/mergo"
+	"golang.org/x/net/http/httpproxy"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	kubeyaml "k8s.io/apimachinery/pkg/runtime/serializer/yaml"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -24,6 +30,7 @@ import (
 // Valid verbs for managed resource bindings
 const (
 	VerbCreate = "create"
+	VerbUpdate = "update"
 	VerbDelete = "delete"
 )
 
@@ -33,20 +40,36 @@ var ObjectSerializer = kubeyaml.NewDecodingSerializer(unstructured.UnstructuredJ
 // ManagedResourceAnnotation is a reference to the objects owner CR
 var ManagedResourceAnnotation = "managedresources.paas.il/owner"
 
-// Namespace is an alias for a namespace string
-// +kubebuilder:validation:MaxLength=63
-// +kubebuilder:validation:Pattern="(^[a-z0-9]([-a-z0-9]*[a-z0-9])?$)|(^[*]$)"
+// RequesterAnnotation is the name of the user who last changed the spec of a managed resource, recorded on the CR and its objects
+var RequesterAnnotation = "managedresources.paas.il/requester"
+
+// RequesterGroupsAnnotation is the comma separated groups of the user who last changed the spec of a managed resource
+var RequesterGroupsAnnotation = "managedresources.paas.il/requester-groups"
+
+// RequesterUIDAnnotation is the UID of the user who last changed the spec of a managed resource
+var RequesterUIDAnnotation = "managedresources.paas.il/requester-uid"
+
+// RequesterExtraAnnotation is the JSON encoded extra attributes of the user who last changed the spec of a managed resource, such as the scopes of a token
+var RequesterExtraAnnotation = "managedresources.paas.il/requester-extra"
+
+// FieldManager is the field manager name used when applying managed objects
+var FieldManager = "managed-resource-operator"
+
+// Namespace is an alias for a namespace string, which may be a glob or a regular expression prefixed with ~ within bindings
+// +kubebuilder:validation:MaxLength=253
+// +kubebuilder:validation:Pattern="(^[-a-z0-9*]+$)|(^~.+$)"
 type Namespace string
 
 // Verb is an alias for a permission verb string
-// +kubebuilder:validation:Enum=create;delete
+// +kubebuilder:validation:Enum=create;update;delete
 type Verb string
 
 // MetadataStruct is a stripped metadata object
 type MetadataStruct struct {
 
+	// Name is the name of the object, which may be a glob or a regular expression prefixed with ~ within bindings
 	// +kubebuilder:validation:MaxLength=253
-	// +kubebuilder:validation:Pattern="(^[a-z0-9]([-a-z0-9]*[a-z0-9])?([.][a-z0-9]([-a-z0-9]*[a-z0-9])?)*$)|(^[*]$)"
+	// +kubebuilder:validation:Pattern="(^[-.a-z0-9*]+$)|(^~.+$)"
 	Name string `json:"name"`
 
 	Namespace Namespace `json:"namespace,omitempty"`
@@ -55,13 +78,38 @@ type MetadataStruct struct {
 // ManagedResourceStruct is a reference to an object to be managed
 type ManagedResourceStruct struct {
 
-	// +kubebuilder:validation:MaxLength=63
-	// +kubebuilder:validation:Pattern="(^[a-zA-Z]([-a-zA-Z0-9]*[a-zA-Z0-9])?$)|(^[*]$)"
+	// APIVersion is the group and version of the object, either of which may be *
+	// +kubebuilder:validation:MaxLength=317
+	// +kubebuilder:validation:Pattern="^(([a-z0-9]([-a-z0-9]*[a-z0-9])?([.][a-z0-9]([-a-z0-9]*[a-z0-9])?)*|[*])/)?([a-z0-9]+|[*])$"
+	// +optional
+	APIVersion string `json:"apiVersion,omitempty"`
+
+	// Group is the API group of the object regardless of its version, where core is the core group
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern="(^[a-z0-9]([-a-z0-9]*[a-z0-9])?([.][a-z0-9]([-a-z0-9]*[a-z0-9])?)*$)|(^[*]$)"
+	// +optional
+	Group string `json:"group,omitempty"`
+
+	// Kind is the kind of the object, or its plural, singular or short resource name, which may be a glob or a regular expression prefixed with ~ within bindings
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern="(^[a-zA-Z*][-a-zA-Z0-9*]*$)|(^~.+$)"
 	Kind string `json:"kind"`
 
 	Metadata MetadataStruct `json:"metadata"`
 }
 
+// SourceReference is a reference to a key of an object within the managed resource namespace
+type SourceReference struct {
+
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern="^[a-z0-9]([-a-z0-9]*[a-z0-9])?([.][a-z0-9]([-a-z0-9]*[a-z0-9])?)*$"
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern="^[-._a-zA-Z0-9]+$"
+	Key string `json:"key"`
+}
+
 // SourceStruct defines options to supply the managed object code
 type SourceStruct struct {
 	URL  string `json:"url,omitempty"`
@@ -71,23 +119,134 @@ type SourceStruct struct {
 	// +kubebuilder:validation:XPreserveUnknownFields
 	// +nullable
 	Object runtime.RawExtension `json:"object,omitempty"`
+
+	// ConfigMapRef reads the managed object code from a ConfigMap key on every reconciliation
+	// +optional
+	ConfigMapRef *SourceReference `json:"configMapRef,omitempty"`
+
+	// SecretRef reads the managed object code from a Secret key on every reconciliation, which must be labeled managedresources.paas.il/source=true
+	// +optional
+	SecretRef *SourceReference `json:"secretRef,omitempty"`
+
+	// Git reads the managed object code from a git repository on every reconciliation
+	// +optional
+	Git *GitSource `json:"git,omitempty"`
+
+	// SHA256 is the hex encoded SHA-256 digest the URL contents must match
+	// +kubebuilder:validation:Pattern="^[a-fA-F0-9]{64}$"
+	// +optional
+	SHA256 string `json:"sha256,omitempty"`
+
+	// SignatureURL is the URL of the detached signature of the URL contents, defaults to the URL with a .sig suffix
+	// +optional
+	SignatureURL string `json:"signatureURL,omitempty"`
+
+	// Auth references the credentials used to fetch the URL and its signature
+	// +optional
+	Auth *URLAuth `json:"auth,omitempty"`
+
+	// Refresh keeps the URL as the source of the managed object code and fetches it again periodically
+	// +optional
+	Refresh *SourceRefresh `json:"refresh,omitempty"`
+
+	// Template renders the managed object code as a template with the managed resource namespace, name, labels and parameters
+	// +optional
+	Template bool `json:"template,omitempty"`
 }
 
 // DeepCopyInto is a custom deep copy method for source struct which controller-gen expects
 func (r *SourceStruct) DeepCopyInto(out *SourceStruct) {
 	(*out).URL = r.URL
 	(*out).YAML = r.YAML
+	(*out).SHA256 = r.SHA256
+	(*out).SignatureURL = r.SignatureURL
+	(*out).Template = r.Template
 	r.Object.DeepCopyInto(&out.Object)
+	if r.ConfigMapRef != nil {
+		configMapRef := *r.ConfigMapRef
+		(*out).ConfigMapRef = &configMapRef
+	}
+	if r.SecretRef != nil {
+		secretRef := *r.SecretRef
+		(*out).SecretRef = &secretRef
+	}
+	if r.Git != nil {
+		git := *r.Git
+		(*out).Git = &git
+	}
+	if r.Auth != nil {
+		auth := *r.Auth
+		(*out).Auth = &auth
+	}
+	if r.Refresh != nil {
+		refresh := *r.Refresh
+		(*out).Refresh = &refresh
+	}
+}
+
+// IsLive checks whether the source is read on every reconciliation instead of being embedded in the managed resource
+func (r SourceStruct) IsLive() bool {
+	return r.ConfigMapRef != nil || r.SecretRef != nil || r.Git != nil || (r.URL != "" && r.Refresh != nil)
+}
+
+// SourceStatus describes the source contents which were last read
+type SourceStatus struct {
+
+	// Revision is the commit the git source was resolved to
+	// +optional
+	Revision string `json:"revision,omitempty"`
+
+	// Digest is the SHA-256 digest of the URL source contents
+	// +optional
+	Digest string `json:"digest,omitempty"`
+
+	// LastFetchTime is the last time the URL source was fetched
+	// +optional
+	// +nullable
+	LastFetchTime *metav1.Time `json:"lastFetchTime,omitempty"`
+}
+
+// DeepCopyInto is a custom deep copy method for source status which controller-gen expects
+func (r *SourceStatus) DeepCopyInto(out *SourceStatus) {
+	*out = *r
+	if r.LastFetchTime != nil {
+		out.LastFetchTime = r.LastFetchTime.DeepCopy()
+	}
+}
+
+// SourceContext is the context in which a source is read
+type SourceContext struct {
+
+	// Client reads objects referenced by the source
+	Client client.Reader
+
+	// Namespace is the namespace of the managed resource
+	Namespace string
+
+	// Name is the name of the managed resource
+	Name string
+
+	// Labels are the labels of the managed resource
+	Labels map[string]string
+
+	// Parameters are the values a templated source is rendered with
+	Parameters map[string]string
+
+	// ParametersConfigMap is the name of a config map within the namespace holding additional values a templated source is rendered with
+	ParametersConfigMap string
 }
 
 // A map of source types and their appropriate retrieval methods
-var sourceFunctions = map[string]func(SourceStruct) ([]byte, error){
-	"URL":    getManagedResourceBytesByURL,
-	"YAML":   getManagedResourceBytesByYAML,
-	"Object": getManagedResourceBytesByObject,
+var sourceFunctions = map[string]func(SourceStruct, SourceContext) ([]byte, SourceStatus, error){
+	"URL":          getManagedResourceBytesByURL,
+	"YAML":         getManagedResourceBytesByYAML,
+	"Object":       getManagedResourceBytesByObject,
+	"ConfigMapRef": getManagedResourceBytesByConfigMap,
+	"SecretRef":    getManagedResourceBytesBySecret,
+	"Git":          getManagedResourceBytesByGit,
 }
 
-func getManagedResourceBytes(sourceStruct SourceStruct) ([]byte, error) {
+func getManagedResourceBytes(sourceStruct SourceStruct, sourceContext SourceContext) ([]byte, SourceStatus, error) {
 
 	// Init resource bytes
 	var managedResourceBytes []byte
@@ -101,41 +260,60 @@ func getManagedResourceBytes(sourceStruct SourceStruct) ([]byte, error) {
 		sourceName := sourceNames.Field(sourceIndex)
 		sourceValue := sourceValues.Field(sourceIndex)
 
+		// Skip fields which only modify how the source is read
+		sourceFunction, ok := sourceFunctions[sourceName.Name]
+		if !ok {
+			continue
+		}
+
 		// Find the defined source type and call the appropriate method
-		if sourceValue.String() != "" {
-			managedResourceBytes, err := sourceFunctions[sourceName.Name](sourceStruct)
+		if !sourceValue.IsZero() {
+			managedResourceBytes, sourceStatus, err := sourceFunction(sourceStruct, sourceContext)
 			if err != nil {
-				return nil, err
+				return nil, SourceStatus{}, err
 			}
-			return managedResourceBytes, nil
+			return managedResourceBytes, sourceStatus, nil
 		}
 	}
 
-	return nil, nil
+	return nil, SourceStatus{}, nil
 }
 
-func getManagedResourceBytesByURL(sourceStruct SourceStruct) ([]byte, error) {
+// Transport of the HTTP configuration it was built for, which is shared by all requests so their connections are reused
+var (
+	sharedTransport       *policyTransport
+	sharedTransportConfig HTTPConfig
+	sharedTransportMux    sync.Mutex
+)
 
-	// Parse HTTP_INSECURE environment variable
-	HTTP_INSECURE_STR, ok := os.LookupEnv("HTTP_INSECURE")
-	if !ok {
-		HTTP_INSECURE_STR = "false"
-	}
-	HTTP_INSECURE, err := strconv.ParseBool(HTTP_INSECURE_STR)
-	if err != nil {
-		return nil, errors.New("an error occurred while parsing HTTP_INSECURE environment variable: " + err.Error())
-	}
+// newHTTPClient returns an HTTP client for the given configuration, using the shared transport which is only rebuilt once the configuration changes
+func newHTTPClient(httpConfig HTTPConfig) (*http.Client, error) {
+	sharedTransportMux.Lock()
+	defer sharedTransportMux.Unlock()
 
-	// Parse HTTP_TIMEOUT environment variable
-	HTTP_TIMEOUT_STR, ok := os.LookupEnv("HTTP_TIMEOUT")
-	if !ok {
-		HTTP_TIMEOUT_STR = "10"
-	}
-	HTTP_TIMEOUT, err := strconv.ParseInt(HTTP_TIMEOUT_STR, 10, 64)
-	if err != nil {
-		return nil, errors.New("an error occurred while parsing HTTP_TIMEOUT environment variable: " + err.Error())
+	if sharedTransport == nil || !reflect.DeepEqual(sharedTransportConfig, httpConfig) {
+		transport, err := newPolicyTransport(httpConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		// Requests of the previous transport still in progress keep their connections until they are done
+		if sharedTransport != nil {
+			sharedTransport.transport.CloseIdleConnections()
+		}
+		sharedTransport, sharedTransportConfig = transport, httpConfig
 	}
 
+	// HTTP client with timeout, checking every request including redirects against the URL policy
+	return &http.Client{
+		Timeout:   httpConfig.Timeout.Duration,
+		Transport: sharedTransport,
+	}, nil
+}
+
+// newPolicyTransport returns a transport for the given configuration which checks every request against its URL policy
+func newPolicyTransport(httpConfig HTTPConfig) (*policyTransport, error) {
+
 	// Get pool of certificates the system trusts
 	systemCertPool, err := x509.SystemCertPool()
 	if err != nil {
@@ -144,107 +322,374 @@ func getManagedResourceBytesByURL(sourceStruct SourceStruct) ([]byte, error) {
 
 	// Define new TLS config
 	tlsConfig := &tls.Config{
-		InsecureSkipVerify: HTTP_INSECURE,
+		InsecureSkipVerify: httpConfig.Insecure,
 		RootCAs:            systemCertPool,
 	}
 
-	// Read and use the CA bundle if present
-	HTTP_CA_BUNDLE_PATH, ok := os.LookupEnv("HTTP_CA_BUNDLE_PATH")
-	if ok {
+	// Add the CA bundle to trusted CAs if present
+	if httpConfig.CABundle != "" {
+		if ok := tlsConfig.RootCAs.AppendCertsFromPEM([]byte(httpConfig.CABundle)); !ok {
+			return nil, errors.New("an error occurred while appending CA bundle to trusted certificate pool")
+		}
+	}
 
-		// Read CA bundle
-		CABundle, err := ioutil.ReadFile(HTTP_CA_BUNDLE_PATH)
-		if err != nil {
-			return nil, errors.New("an error occurred while reading CA bundle: " + err.Error())
+	// Refuse connections to addresses which are blocked by the URL policy
+	policy, err := newURLPolicy(httpConfig)
+	if err != nil {
+		return nil, errors.New("an error occurred while reading URL policy: " + err.Error())
+	}
+	dialer := &net.Dialer{
+		Timeout:   httpConfig.Timeout.Duration,
+		KeepAlive: 30 * time.Second,
+		Control:   policy.dialControl,
+	}
+
+	// Send requests through the proxy if present
+	transport := &http.Transport{
+		TLSClientConfig:     tlsConfig,
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	if httpConfig.Proxy != "" {
+		proxyFunc := (&httpproxy.Config{
+			HTTPProxy:  httpConfig.Proxy,
+			HTTPSProxy: httpConfig.Proxy,
+			NoProxy:    httpConfig.NoProxy,
+		}).ProxyFunc()
+		transport.Proxy = func(request *http.Request) (*url.URL, error) {
+			return proxyFunc(request.URL)
 		}
+	}
+
+	return &policyTransport{
+		policy:    policy,
+		transport: transport,
+	}, nil
+}
+
+// configuredTransport sends requests with the HTTP configuration and URL policy which are current when the request is sent
+type configuredTransport struct{}
+
+// RoundTrip implements http.RoundTripper
+func (configuredTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+
+	client, err := newHTTPClient(CurrentHTTPConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Transport.RoundTrip(request)
+}
+
+func getManagedResourceBytesByURL(sourceStruct SourceStruct, sourceContext SourceContext) ([]byte, SourceStatus, error) {
+
+	if !CurrentFeatures().URLSourcesEnabled() {
+		return nil, SourceStatus{}, errors.New("URL sources are disabled by the operator configuration")
+	}
+
+	// HTTP client for the source and its signature
+	httpConfig := CurrentHTTPConfig()
+	client, err := newHTTPClient(httpConfig)
+	if err != nil {
+		return nil, SourceStatus{}, err
+	}
+
+	// Read credentials of the URL, which are never sent to another origin on redirects
+	headers, err := authHeaders(sourceStruct, sourceContext)
+	if err != nil {
+		return nil, SourceStatus{}, err
+	}
+	client.CheckRedirect = credentialsRedirectPolicy(headers)
 
-		// Add bundle to trusted CAs
-		if ok := tlsConfig.RootCAs.AppendCertsFromPEM(CABundle); !ok {
-			return nil, errors.New("an error occurred while appeding CA bundle to trusted certificate pool: " + err.Error())
+	// Reuse contents recently fetched for the same source, only checking them against the pinned digest
+	key := sourceCacheKey(sourceStruct, sourceContext)
+	cacheHit := func(entry sourceCacheEntry) ([]byte, SourceStatus, error) {
+		if err := verifyDigest(sourceStruct, entry.body); err != nil {
+			return nil, SourceStatus{}, err
 		}
+		sourceCacheRequests.WithLabelValues(cacheResultHit).Inc()
+		return entry.body, SourceStatus{Digest: Digest(entry.body), LastFetchTime: &entry.fetchTime}, nil
+	}
+	if entry, cached, fresh := cachedSource(key, httpConfig.CacheTTL.Duration); cached && fresh {
+		return cacheHit(entry)
+	}
+
+	// Wait for fetches of the same source and limit concurrent requests to the host, then check whether another fetch filled the cache meanwhile
+	unlock := lockSource(key)
+	defer unlock()
+	release := acquireHost(sourceStruct.URL, httpConfig.MaxRequestsPerHost)
+	defer release()
+	entry, cached, fresh := cachedSource(key, httpConfig.CacheTTL.Duration)
+	if cached && fresh {
+		return cacheHit(entry)
 	}
 
-	// HTTP client with timeout
-	client := &http.Client{
-		Timeout: time.Duration(HTTP_TIMEOUT) * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: tlsConfig,
-		},
+	// Build request, making it conditional if the contents were fetched before
+	request, err := newAuthenticatedRequest(sourceStruct.URL, headers)
+	if err != nil {
+		return nil, SourceStatus{}, errors.New("an error occurred while querying " + sourceStruct.URL + ": " + err.Error())
+	}
+	if cached {
+		setConditionalHeaders(request, entry)
 	}
 
 	// Get resource yaml from remote
-	response, err := client.Get(sourceStruct.URL)
+	fetchTime := metav1.Now()
+	response, err := client.Do(request)
 	if err != nil {
-		return nil, errors.New("an error occurred while querying " + sourceStruct.URL + ": " + err.Error())
-	} else if response.StatusCode != 200 {
-		return nil, errors.New("an error occurred while querying " + sourceStruct.URL + ": " + response.Status)
+		return nil, SourceStatus{}, errors.New("an error occurred while querying " + sourceStruct.URL + ": " + err.Error())
 	}
 	defer response.Body.Close()
 
-	// Read response as byte array
-	body, err := ioutil.ReadAll(response.Body)
+	// Reuse the cached contents if the URL did not change
+	if response.StatusCode == http.StatusNotModified && cached {
+		if err := verifyContents(client, httpConfig, headers, sourceStruct, entry.body); err != nil {
+			return nil, SourceStatus{}, err
+		}
+		entry.fetchTime = fetchTime
+		if etag := response.Header.Get("ETag"); etag != "" {
+			entry.etag = etag
+		}
+		if lastModified := response.Header.Get("Last-Modified"); lastModified != "" {
+			entry.lastModified = lastModified
+		}
+		storeSource(key, entry)
+		sourceCacheRequests.WithLabelValues(cacheResultRevalidated).Inc()
+		return entry.body, SourceStatus{Digest: Digest(entry.body), LastFetchTime: &fetchTime}, nil
+	}
+	sourceCacheRequests.WithLabelValues(cacheResultMiss).Inc()
+	if response.StatusCode != http.StatusOK {
+		return nil, SourceStatus{}, errors.New("an error occurred while querying " + sourceStruct.URL + ": " + response.Status)
+	}
+
+	// Read response as byte array, rejecting oversize, truncated and non-manifest responses
+	body, err := readLimitedBody(response, sourceStruct.URL, httpConfig.MaxBodySize.Value())
 	if err != nil {
-		return nil, errors.New("an error occurred while reading response from " + sourceStruct.URL + ": " + err.Error())
+		return nil, SourceStatus{}, err
+	}
+	if err := checkManifestResponse(response, sourceStruct.URL, body, httpConfig.AllowedContentTypes); err != nil {
+		return nil, SourceStatus{}, err
 	}
 
-	return body, nil
+	// Ensure the contents are the ones that were pinned or signed
+	if err := verifyContents(client, httpConfig, headers, sourceStruct, body); err != nil {
+		return nil, SourceStatus{}, err
+	}
+
+	// Share the verified contents with other reads of the source
+	storeSource(key, sourceCacheEntry{
+		body:         body,
+		etag:         response.Header.Get("ETag"),
+		lastModified: response.Header.Get("Last-Modified"),
+		fetchTime:    fetchTime,
+	})
+
+	return body, SourceStatus{Digest: Digest(body), LastFetchTime: &fetchTime}, nil
 }
 
-func getManagedResourceBytesByYAML(sourceStruct SourceStruct) ([]byte, error) {
-	return []byte(sourceStruct.YAML), nil
+func getManagedResourceBytesByYAML(sourceStruct SourceStruct, sourceContext SourceContext) ([]byte, SourceStatus, error) {
+	return []byte(sourceStruct.YAML), SourceStatus{}, nil
 }
 
-func getManagedResourceBytesByObject(sourceStruct SourceStruct) ([]byte, error) {
+func getManagedResourceBytesByObject(sourceStruct SourceStruct, sourceContext SourceContext) ([]byte, SourceStatus, error) {
 
 	// Write raw json bytes as yaml bytes
 	embeddedYAMLBytes, err := yaml.JSONToYAML(sourceStruct.Object.Raw)
 	if err != nil {
-		return nil, errors.New("an error occurred while reading object: " + err.Error())
+		return nil, SourceStatus{}, errors.New("an error occurred while reading object: " + err.Error())
 	}
 
-	return embeddedYAMLBytes, nil
+	return embeddedYAMLBytes, SourceStatus{}, nil
 }
 
-// ProcessSource reads ManagedObject source struct and returns its relevant formats
-func ProcessSource(source SourceStruct) ([]byte, *ManagedResourceStruct, runtime.Object, types.NamespacedName, error) {
+func getManagedResourceBytesByConfigMap(sourceStruct SourceStruct, sourceContext SourceContext) ([]byte, SourceStatus, error) {
 
-	// Get managed resource bytes
-	managedResourceBytes, err := getManagedResourceBytes(source)
-	if err != nil {
-		return nil, nil, nil, types.NamespacedName{}, errors.New("an error occurred while trying to read the source: " + err.Error())
-	} else if managedResourceBytes == nil {
-		return nil, nil, nil, types.NamespacedName{}, errors.New("an error occurred while trying to read the source: a single source must be defined")
+	// Get referenced config map
+	configMap := &corev1.ConfigMap{}
+	if err := sourceContext.Client.Get(context.Background(), types.NamespacedName{Namespace: sourceContext.Namespace, Name: sourceStruct.ConfigMapRef.Name}, configMap); err != nil {
+		return nil, SourceStatus{}, errors.New("an error occurred while reading config map " + sourceStruct.ConfigMapRef.Name + ": " + err.Error())
 	}
 
+	// Read referenced key as either text or binary data
+	if data, ok := configMap.Data[sourceStruct.ConfigMapRef.Key]; ok {
+		return []byte(data), SourceStatus{}, nil
+	} else if binaryData, ok := configMap.BinaryData[sourceStruct.ConfigMapRef.Key]; ok {
+		return binaryData, SourceStatus{}, nil
+	}
+
+	return nil, SourceStatus{}, errors.New("an error occurred while reading config map " + sourceStruct.ConfigMapRef.Name + ": key " + sourceStruct.ConfigMapRef.Key + " not found")
+}
+
+func getManagedResourceBytesBySecret(sourceStruct SourceStruct, sourceContext SourceContext) ([]byte, SourceStatus, error) {
+
+	// Get referenced secret
+	secret := &corev1.Secret{}
+	if err := sourceContext.Client.Get(context.Background(), types.NamespacedName{Namespace: sourceContext.Namespace, Name: sourceStruct.SecretRef.Name}, secret); err != nil {
+		return nil, SourceStatus{}, errors.New("an error occurred while reading secret " + sourceStruct.SecretRef.Name + ": " + err.Error())
+	}
+
+	// Only read secrets which opted in to be sources, which service account tokens may never do
+	if secret.Type == corev1.SecretTypeServiceAccountToken {
+		return nil, SourceStatus{}, errors.New("an error occurred while reading secret " + sourceStruct.SecretRef.Name + ": service account token secrets may not be used as sources")
+	}
+	if secret.Labels[SourceLabel] != "true" {
+		return nil, SourceStatus{}, errors.New("an error occurred while reading secret " + sourceStruct.SecretRef.Name + ": secret must be labeled " + SourceLabel + "=true to be used as a source")
+	}
+
+	// Read referenced key
+	data, ok := secret.Data[sourceStruct.SecretRef.Key]
+	if !ok {
+		return nil, SourceStatus{}, errors.New("an error occurred while reading secret " + sourceStruct.SecretRef.Name + ": key " + sourceStruct.SecretRef.Key + " not found")
+	}
+
+	return data, SourceStatus{}, nil
+}
+
+// ManagedObject is a single object read from a managed resource source
+type ManagedObject struct {
+	Bytes  []byte
+	Struct *ManagedResourceStruct
+	Object runtime.Object
+	Key    types.NamespacedName
+}
+
+// Identity uniquely identifies the object within the cluster regardless of its API version
+func (o ManagedObject) Identity() string {
+	return ObjectIdentity(o.Object.GetObjectKind().GroupVersionKind().GroupKind(), o.Key)
+}
+
+// String returns a human readable reference to the object
+func (o ManagedObject) String() string {
+	return o.Struct.Kind + " " + o.Key.String()
+}
+
+// ObjectIdentity uniquely identifies an object within the cluster regardless of its API version
+func ObjectIdentity(groupKind schema.GroupKind, key types.NamespacedName) string {
+	return groupKind.String() + " " + key.String()
+}
+
+// ProcessObject reads a single object document and returns its relevant formats
+func ProcessObject(managedResourceBytes []byte) (ManagedObject, error) {
+
 	// Unmarshal bytes to managed resource struct
 	managedResourceStruct := &ManagedResourceStruct{}
 	if err := yaml.Unmarshal(managedResourceBytes, managedResourceStruct); err != nil {
-		return nil, nil, nil, types.NamespacedName{}, errors.New("an error occurred while trying to read object data: " + err.Error())
+		return ManagedObject{}, errors.New("an error occurred while trying to read object data: " + err.Error())
 	}
 
 	// Decode managed resource bytes to runtime object
 	managedObject, _, err := ObjectSerializer.Decode(managedResourceBytes, nil, &unstructured.Unstructured{})
 	if err != nil {
-		return nil, nil, nil, types.NamespacedName{}, errors.New("an error occurred while trying to unmarshal object yaml: " + err.Error())
+		return ManagedObject{}, errors.New("an error occurred while trying to unmarshal object yaml: " + decodeErrorMessage(err))
 	}
 
 	// Get managed object key
 	managedObjectKey, err := client.ObjectKeyFromObject(managedObject)
 	if err != nil {
-		return nil, nil, nil, types.NamespacedName{}, errors.New("an error occurred while trying to get object key: " + err.Error())
+		return ManagedObject{}, errors.New("an error occurred while trying to get object key: " + err.Error())
+	}
+
+	return ManagedObject{
+		Bytes:  managedResourceBytes,
+		Struct: managedResourceStruct,
+		Object: managedObject,
+		Key:    managedObjectKey,
+	}, nil
+}
+
+// decodeErrorMessage describes a decoding error without the decoded data, which missing kind and version errors contain and may be read from a secret
+func decodeErrorMessage(err error) string {
+
+	if runtime.IsMissingKind(err) {
+		return "object kind is missing"
+	} else if runtime.IsMissingVersion(err) {
+		return "object apiVersion is missing"
+	}
+
+	return err.Error()
+}
+
+// ProcessSource reads ManagedObject source struct and returns its objects in the order they should be applied along with the source status
+func ProcessSource(source SourceStruct, sourceContext SourceContext) ([]ManagedObject, SourceStatus, error) {
+
+	// Only URL sources may be refreshed, verified or authenticated
+	if source.URL == "" && (source.Refresh != nil || source.SHA256 != "" || source.SignatureURL != "" || source.Auth != nil) {
+		return nil, SourceStatus{}, errors.New("an error occurred while trying to read the source: refresh, sha256, signatureURL and auth are only supported for URL sources")
+	}
+
+	// Only URL sources come with a detached signature, so no other source may be used once signatures are required
+	if source.URL == "" && SignaturesRequired() {
+		return nil, SourceStatus{}, errors.New("an error occurred while trying to read the source: the operator requires signed sources, which only URL sources can be")
+	}
+
+	// Only templated sources are rendered with parameters
+	if !source.Template && (len(sourceContext.Parameters) != 0 || sourceContext.ParametersConfigMap != "") {
+		return nil, SourceStatus{}, errors.New("an error occurred while trying to read the source: parameters are only supported for templated sources")
+	}
+
+	// Get managed resource bytes
+	managedResourceBytes, sourceStatus, err := getManagedResourceBytes(source, sourceContext)
+	if err != nil {
+		return nil, SourceStatus{}, fmt.Errorf("an error occurred while trying to read the source: %w", err)
+	} else if managedResourceBytes == nil {
+		return nil, SourceStatus{}, errors.New("an error occurred while trying to read the source: a single source must be defined")
+	}
+
+	// Render templated sources so their objects are read and permitted as rendered
+	if source.Template {
+		if managedResourceBytes, err = renderTemplate(managedResourceBytes, sourceContext); err != nil {
+			return nil, SourceStatus{}, errors.New("an error occurred while trying to read the source: " + err.Error())
+		}
+	}
+
+	// Split source into single object documents
+	documents, err := splitDocuments(managedResourceBytes)
+	if err != nil {
+		return nil, SourceStatus{}, errors.New("an error occurred while trying to read the source: " + err.Error())
+	} else if len(documents) == 0 {
+		return nil, SourceStatus{}, errors.New("an error occurred while trying to read the source: source contains no objects")
 	}
 
-	return managedResourceBytes, managedResourceStruct, managedObject, managedObjectKey, nil
+	// Process each object, ensuring no object is defined twice
+	managedObjects := make([]ManagedObject, 0, len(documents))
+	identities := make(map[string]bool)
+	for _, document := range documents {
+
+		managedObject, err := ProcessObject(document)
+		if err != nil {
+			return nil, SourceStatus{}, err
+		}
+
+		if identities[managedObject.Identity()] {
+			return nil, SourceStatus{}, errors.New("an error occurred while trying to read the source: " + managedObject.String() + " is defined more than once")
+		}
+		identities[managedObject.Identity()] = true
+
+		managedObjects = append(managedObjects, managedObject)
+	}
+
+	sortManagedObjects(managedObjects)
+
+	return managedObjects, sourceStatus, nil
 }
 
-// ProcessOverwrite merges resource and overwrite fields
-func ProcessOverwrite(managedResourceBytes []byte, overwrite runtime.RawExtension) ([]byte, error) {
+// ProcessOverwrite merges resource and overwrite fields using the overwrite strategy
+func ProcessOverwrite(managedResourceBytes []byte, overwrite runtime.RawExtension, strategy OverwriteStrategy) ([]byte, error) {
 
 	// Do not overwrite if there is nothing to overwrite with
 	if overwrite.Raw == nil {
 		return managedResourceBytes, nil
 	}
 
+	// Merge as a patch if requested, otherwise union merge the maps
+	switch strategy {
+	case OverwriteStrategyJSONMerge:
+		return jsonMergeOverwrite(managedResourceBytes, overwrite)
+	case OverwriteStrategyStrategicMerge:
+		return strategicMergeOverwrite(managedResourceBytes, overwrite)
+	}
+
 	// Define empty maps for resource and overwrite
 	var managedResourceMap map[string]interface{}
 	var overwriteMap map[string]interface{}
@@ -270,3 +715,31 @@ func ProcessOverwrite(managedResourceBytes []byte, overwrite runtime.RawExtensio
 
 	return managedResourceBytesOverwrite, nil
 }
+
+// OverwriteObjects merges overwrite fields into each of the objects using the overwrite strategy and returns them in the order they should be applied
+func OverwriteObjects(managedObjects []ManagedObject, overwrite runtime.RawExtension, strategy OverwriteStrategy) ([]ManagedObject, error) {
+
+	// Do not overwrite if there is nothing to overwrite with
+	if overwrite.Raw == nil {
+		return managedObjects, nil
+	}
+
+	overwrittenObjects := make([]ManagedObject, 0, len(managedObjects))
+	for _, managedObject := range managedObjects {
+
+		managedObjectBytes, err := ProcessOverwrite(managedObject.Bytes, overwrite, strategy)
+		if err != nil {
+			return nil, err
+		}
+
+		overwrittenObject, err := ProcessObject(managedObjectBytes)
+		if err != nil {
+			return nil, err
+		}
+		overwrittenObjects = append(overwrittenObjects, overwrittenObject)
+	}
+
+	sortManagedObjects(overwrittenObjects)
+
+	return overwrittenObjects, nil
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNewHTTPClientSharesTransport(t *testing.T) {
+
+	httpConfig := HTTPConfig{}.WithDefaults()
+	first, err := newHTTPClient(httpConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := newHTTPClient(httpConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Transport != second.Transport {
+		t.Error("expected clients of the same configuration to share their transport")
+	}
+
+	// A changed configuration rebuilds the transport
+	httpConfig.Timeout = metav1.Duration{Duration: time.Minute}
+	changed, err := newHTTPClient(httpConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed.Transport == first.Transport {
+		t.Error("expected a changed configuration to rebuild the transport")
+	}
+}
+
+func TestGetManagedResourceBytesBySecret(t *testing.T) {
+
+	dockerConfig := `{"auths":{"registry":{"auth":"c2VjcmV0OnBhc3M="}}}`
+	secret := func(name string, secretType corev1.SecretType, labels map[string]string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "tenant", Name: name, Labels: labels},
+			Type:       secretType,
+			Data:       map[string][]byte{"object.yaml": []byte(dockerConfig)},
+		}
+	}
+	optedIn := map[string]string{SourceLabel: "true"}
+
+	sourceContext := SourceContext{
+		Namespace: "tenant",
+		Client: fake.NewFakeClientWithScheme(scheme.Scheme,
+			secret("labeled", corev1.SecretTypeOpaque, optedIn),
+			secret("unlabeled", corev1.SecretTypeDockerConfigJson, nil),
+			secret("credentials", corev1.SecretTypeOpaque, map[string]string{CredentialsLabel: "true"}),
+			secret("service-account-token", corev1.SecretTypeServiceAccountToken, optedIn),
+		),
+	}
+
+	// Refused secrets are not read, and invalid objects do not reveal the contents of the secret
+	tests := map[string]string{
+		"labeled":               "object kind is missing",
+		"unlabeled":             SourceLabel,
+		"credentials":           SourceLabel,
+		"service-account-token": "service account token",
+	}
+
+	for name, wantErr := range tests {
+		t.Run(name, func(t *testing.T) {
+
+			_, _, err := ProcessSource(SourceStruct{SecretRef: &SourceReference{Name: name, Key: "object.yaml"}}, sourceContext)
+			if err == nil || !strings.Contains(err.Error(), wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", wantErr, err)
+			}
+			if strings.Contains(err.Error(), "c2VjcmV0OnBhc3M=") {
+				t.Errorf("error contains the secret contents: %v", err)
+			}
+		})
+	}
+}
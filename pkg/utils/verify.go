@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// signatureSuffix is appended to the URL of a source to get its detached signature if no signature URL is set
+const signatureSuffix = ".sig"
+
+// verifyContents ensures the URL contents match the pinned digest and are signed by the cluster-wide public key if one is configured
+func verifyContents(client *http.Client, httpConfig HTTPConfig, headers http.Header, sourceStruct SourceStruct, contents []byte) error {
+
+	if err := verifyDigest(sourceStruct, contents); err != nil {
+		return err
+	}
+
+	// Signatures are only verified if a public key is configured
+	publicKey, err := currentSignatureKey()
+	if err != nil {
+		return errors.New("an error occurred while reading signature public key: " + err.Error())
+	} else if publicKey == nil {
+		return nil
+	}
+
+	// Get detached signature from remote
+	signatureURL := sourceStruct.SignatureURL
+	if signatureURL == "" {
+		signatureURL = sourceStruct.URL + signatureSuffix
+	}
+	request, err := newAuthenticatedRequest(signatureURL, signatureHeaders(sourceStruct.URL, signatureURL, headers))
+	if err != nil {
+		return errors.New("an error occurred while querying " + signatureURL + ": " + err.Error())
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return errors.New("an error occurred while querying " + signatureURL + ": " + err.Error())
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return errors.New("an error occurred while querying " + signatureURL + ": " + response.Status)
+	}
+	signature, err := readLimitedBody(response, signatureURL, httpConfig.MaxBodySize.Value())
+	if err != nil {
+		return err
+	}
+
+	if err := publicKey.verify(contents, signature); err != nil {
+		return errors.New("an error occurred while verifying " + sourceStruct.URL + " signature: " + err.Error())
+	}
+
+	return nil
+}
+
+// signatureHeaders returns the credentials of the source for its signature only if both are served by the same origin
+func signatureHeaders(sourceURL string, signatureURL string, headers http.Header) http.Header {
+
+	parsedSourceURL, sourceErr := url.Parse(sourceURL)
+	parsedSignatureURL, signatureErr := url.Parse(signatureURL)
+	if sourceErr != nil || signatureErr != nil || !sameOrigin(parsedSourceURL, parsedSignatureURL) {
+		return http.Header{}
+	}
+
+	return headers
+}
+
+// verifyDigest compares the URL contents with the pinned digest if one is set
+func verifyDigest(sourceStruct SourceStruct, contents []byte) error {
+
+	if sourceStruct.SHA256 != "" {
+		sum := sha256.Sum256(contents)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), sourceStruct.SHA256) {
+			return errors.New("an error occurred while verifying " + sourceStruct.URL + ": contents do not match sha256 " + sourceStruct.SHA256)
+		}
+	}
+
+	return nil
+}
+
+// publicKey is a parsed GPG or PEM encoded public key detached signatures are verified with
+type publicKey struct {
+	keyring   openpgp.EntityList
+	publicKey crypto.PublicKey
+}
+
+// parsePublicKey parses either a GPG key or a PEM encoded public key
+func parsePublicKey(data []byte) (*publicKey, error) {
+
+	// Read GPG keys as a keyring
+	if bytes.Contains(data, []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----")) {
+		keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+
+		return &publicKey{keyring: keyring}, nil
+	}
+
+	// Parse PEM encoded public key
+	publicKeyBlock, _ := pem.Decode(data)
+	if publicKeyBlock == nil {
+		return nil, errors.New("public key is neither a GPG nor a PEM encoded key")
+	}
+	parsedPublicKey, err := x509.ParsePKIXPublicKey(publicKeyBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parsedPublicKey.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+	default:
+		return nil, errors.New("unsupported public key type")
+	}
+
+	return &publicKey{publicKey: parsedPublicKey}, nil
+}
+
+// verify verifies a detached signature of the contents
+func (k *publicKey) verify(contents []byte, signature []byte) error {
+
+	// Verify GPG signatures with the keyring
+	if k.keyring != nil {
+		var err error
+		if bytes.Contains(signature, []byte("-----BEGIN PGP SIGNATURE-----")) {
+			_, err = openpgp.CheckArmoredDetachedSignature(k.keyring, bytes.NewReader(contents), bytes.NewReader(signature))
+		} else {
+			_, err = openpgp.CheckDetachedSignature(k.keyring, bytes.NewReader(contents), bytes.NewReader(signature))
+		}
+		return err
+	}
+
+	// Signatures are base64 encoded like cosign signatures, but raw signatures are accepted as well
+	if decodedSignature, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature))); err == nil {
+		signature = decodedSignature
+	}
+
+	// Verify signature of the contents digest
+	sum := sha256.Sum256(contents)
+	switch key := k.publicKey.(type) {
+	case *ecdsa.PublicKey:
+		var ecdsaSignature struct {
+			R, S *big.Int
+		}
+		if _, err := asn1.Unmarshal(signature, &ecdsaSignature); err != nil {
+			return err
+		}
+		if !ecdsa.Verify(key, sum[:], ecdsaSignature.R, ecdsaSignature.S) {
+			return errors.New("invalid signature")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], signature); err != nil {
+			return err
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, contents, signature) {
+			return errors.New("invalid signature")
+		}
+	default:
+		return errors.New("unsupported public key type")
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+)
+
+// newSigningKey returns a PEM encoded Ed25519 public key with its private key
+func newSigningKey(t *testing.T) (string, ed25519.PrivateKey) {
+
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), privateKey
+}
+
+func TestPublicKeyVerify(t *testing.T) {
+
+	encodedKey, privateKey := newSigningKey(t)
+	key, err := parsePublicKey([]byte(encodedKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := []byte("apiVersion: v1\nkind: ConfigMap\n")
+	signature := []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, contents)))
+
+	if err := key.verify(contents, signature); err != nil {
+		t.Errorf("expected a valid signature, got %v", err)
+	}
+	if err := key.verify([]byte("apiVersion: v1\nkind: Secret\n"), signature); err == nil {
+		t.Error("expected an error for tampered contents")
+	}
+	if err := key.verify(contents, []byte("not a signature")); err == nil {
+		t.Error("expected an error for a malformed signature")
+	}
+
+	if _, err := parsePublicKey([]byte("not a key")); err == nil {
+		t.Error("expected an error for a malformed key")
+	}
+}
+
+func TestProcessSourceRequiresSignedSources(t *testing.T) {
+
+	encodedKey, _ := newSigningKey(t)
+	Configure(HTTPConfig{}, FeaturesConfig{}, SignaturesConfig{PublicKey: encodedKey})
+	defer Configure(HTTPConfig{}, FeaturesConfig{}, SignaturesConfig{})
+
+	if !SignaturesRequired() {
+		t.Fatal("expected signatures to be required")
+	}
+
+	source := SourceStruct{YAML: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\n"}
+	_, _, err := ProcessSource(source, SourceContext{})
+	if err == nil || !strings.Contains(err.Error(), "requires signed sources") {
+		t.Errorf("expected a YAML source to be rejected, got %v", err)
+	}
+}